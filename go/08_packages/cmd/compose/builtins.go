@@ -0,0 +1,175 @@
+// Copyright Kirk Rader 2024
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+	"parasaurolophus/tutorial/08_packages/lib/expr"
+)
+
+// Parameters for Transformers that take an integer.
+type integer struct {
+	N int `json:"n"`
+}
+
+// Parameters for Transformers that take a number.
+type number struct {
+	N float64 `json:"n"`
+}
+
+// Parameters for Transformers that take a string.
+type text struct {
+	S string `json:"s"`
+}
+
+// Parameters for the field Transformer.
+type field struct {
+	Name string `json:"name"`
+}
+
+// Parameters for the expr Transformer.
+type expression struct {
+	Input  expr.Type `json:"input"`
+	Source string    `json:"expr"`
+}
+
+// Return a Registry containing the Transformers available on the command line.
+//
+// Records start out as strings when read as text, and as whatever
+// encoding/json produces, i.e. float64, string, bool, nil, []any or
+// map[string]any, when read as JSON. Use int, float or string to convert them
+// to the type expected by subsequent stages.
+//
+// The expr Transformer evaluates an expression compiled by expr.Compile() with
+// the Type named by input, which defaults to int, e.g.
+// `expr{"input": "string", "expr": "upper(x) + \"!\""}`.
+func builtins() *lib.Registry {
+
+	registry := lib.NewRegistry()
+
+	must(registry.Register("int", lib.Transformer(toInt)))
+	must(registry.Register("float", lib.Transformer(toFloat)))
+	must(registry.Register("string", lib.Transformer(func(value any) any { return fmt.Sprint(value) })))
+	must(registry.Register("upper", lib.MakeTransformer(strings.ToUpper)))
+	must(registry.Register("lower", lib.MakeTransformer(strings.ToLower)))
+	must(registry.Register("trim", lib.MakeTransformer(strings.TrimSpace)))
+	must(registry.Register("len", lib.MakeMapper(func(s string) int { return len(s) })))
+
+	must(lib.RegisterParameterized(registry, "add", func(p integer) (lib.Transformer, error) {
+		return lib.MakeTransformer(func(value int) int { return value + p.N }), nil
+	}))
+
+	must(lib.RegisterParameterized(registry, "mul", func(p integer) (lib.Transformer, error) {
+		return lib.MakeTransformer(func(value int) int { return value * p.N }), nil
+	}))
+
+	must(lib.RegisterParameterized(registry, "fadd", func(p number) (lib.Transformer, error) {
+		return lib.MakeTransformer(func(value float64) float64 { return value + p.N }), nil
+	}))
+
+	must(lib.RegisterParameterized(registry, "fmul", func(p number) (lib.Transformer, error) {
+		return lib.MakeTransformer(func(value float64) float64 { return value * p.N }), nil
+	}))
+
+	must(lib.RegisterParameterized(registry, "prefix", func(p text) (lib.Transformer, error) {
+		return lib.MakeTransformer(func(value string) string { return p.S + value }), nil
+	}))
+
+	must(lib.RegisterParameterized(registry, "suffix", func(p text) (lib.Transformer, error) {
+		return lib.MakeTransformer(func(value string) string { return value + p.S }), nil
+	}))
+
+	must(lib.RegisterParameterized(registry, "field", func(p field) (lib.Transformer, error) {
+
+		if p.Name == "" {
+			return nil, fmt.Errorf("name is required")
+		}
+
+		return lib.MakeFallibleTransformer(func(value map[string]any) (map[string]any, error) {
+
+			if _, ok := value[p.Name]; !ok {
+				return nil, fmt.Errorf("no field named %q", p.Name)
+			}
+
+			return value, nil
+		}), nil
+	}))
+
+	must(lib.RegisterParameterized(registry, "get", func(p field) (lib.Transformer, error) {
+
+		if p.Name == "" {
+			return nil, fmt.Errorf("name is required")
+		}
+
+		return lib.MakeMapper(func(value map[string]any) any { return value[p.Name] }), nil
+	}))
+
+	must(lib.RegisterParameterized(registry, "expr", func(p expression) (lib.Transformer, error) {
+		return expr.Compile(p.Source, p.Input, nil)
+	}))
+
+	return registry
+}
+
+// Convert a string or JSON number to an int, panicking if that is not
+// possible.
+func toInt(value any) any {
+
+	switch v := value.(type) {
+
+	case int:
+		return v
+
+	case float64:
+		if v != float64(int(v)) {
+			panic(fmt.Errorf("%v is not an integer", v))
+		}
+		return int(v)
+
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			panic(err)
+		}
+		return n
+
+	default:
+		panic(fmt.Errorf("cannot convert %v of type %T to int", v, v))
+	}
+}
+
+// Convert a string or number to a float64, panicking if that is not possible.
+func toFloat(value any) any {
+
+	switch v := value.(type) {
+
+	case float64:
+		return v
+
+	case int:
+		return float64(v)
+
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			panic(err)
+		}
+		return f
+
+	default:
+		panic(fmt.Errorf("cannot convert %v of type %T to float64", v, v))
+	}
+}
+
+// Panic if err is not nil, which is only the case when the built-in
+// Transformers are registered incorrectly.
+func must(err error) {
+
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright Kirk Rader 2024
+
+// Command compose applies a pipeline of registered Transformers to each
+// newline-delimited record read from stdin, writing results to stdout and
+// per-record errors to stderr.
+//
+// Usage:
+//
+//	compose [flags] [stage ...]
+//
+// Each stage is the name of a built-in Transformer, optionally followed by
+// its parameters as a JSON object, e.g.:
+//
+//	printf '1\n2\nthree\n' | compose int 'add{"n":3}' 'mul{"n":2}'
+//
+// prints:
+//
+//	8
+//	10
+//
+// to stdout and:
+//
+//	record 3: Compose() recovered from a panic in Transformer 0 (three of type string): ...
+//
+// to stderr, and exits with status 1. Alternatively, use -f to read the
+// pipeline from a file in the format accepted by lib.Registry.Load(). Use -list
+// to print the names of the built-in Transformers, or -describe to print the
+// structure of the pipeline, e.g. as a Graphviz diagram:
+//
+//	compose -describe dot int 'add{"n":3}' | dot -Tsvg > pipeline.svg
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+// Parsed command-line flags.
+type options struct {
+	file     string
+	input    string
+	output   string
+	failFast bool
+	parallel int
+	list     bool
+	describe string
+}
+
+// A record read from stdin, numbered from 1.
+type record struct {
+	number int
+	value  any
+	err    error
+}
+
+// Run the command, returning its exit status.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("compose", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	opts := options{}
+	flags.StringVar(&opts.file, "f", "", "read the pipeline definition from the given JSON `file`")
+	flags.StringVar(&opts.input, "input", "text", "input `format`: text (one string per line) or json (one JSON value per line)")
+	flags.StringVar(&opts.output, "output", "text", "output `format`: text or json")
+	flags.BoolVar(&opts.failFast, "fail-fast", false, "stop at the first record that fails instead of continuing with the next")
+	flags.IntVar(&opts.parallel, "parallel", 1, "`number` of records to process concurrently; output order is preserved")
+	flags.BoolVar(&opts.list, "list", false, "print the names of the built-in Transformers and exit")
+	flags.StringVar(&opts.describe, "describe", "", "print the pipeline's structure in the given `format`, text or dot, and exit")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	registry := builtins()
+
+	if opts.list {
+		for _, name := range registry.Names() {
+			fmt.Fprintln(stdout, name)
+		}
+		return 0
+	}
+
+	if err := opts.check(); err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 2
+	}
+
+	transformers, err := pipeline(registry, opts.file, flags.Args())
+
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 2
+	}
+
+	switch opts.describe {
+	case "text":
+		fmt.Fprint(stdout, lib.Describe(transformers...).String())
+		return 0
+	case "dot":
+		fmt.Fprint(stdout, lib.Describe(transformers...).DOT())
+		return 0
+	}
+
+	return process(opts, transformers, stdin, stdout, stderr)
+}
+
+// Return an error if the given combination of flags is invalid.
+func (opts options) check() error {
+
+	if opts.input != "text" && opts.input != "json" {
+		return fmt.Errorf("unsupported input format %q", opts.input)
+	}
+
+	if opts.output != "text" && opts.output != "json" {
+		return fmt.Errorf("unsupported output format %q", opts.output)
+	}
+
+	if opts.describe != "" && opts.describe != "text" && opts.describe != "dot" {
+		return fmt.Errorf("unsupported description format %q", opts.describe)
+	}
+
+	if opts.parallel < 1 {
+		return fmt.Errorf("-parallel must be at least 1, got %d", opts.parallel)
+	}
+
+	return nil
+}
+
+// Return the Transformers defined by the given file, if any, followed by those
+// described by the given stage specifications.
+func pipeline(registry *lib.Registry, file string, specs []string) ([]lib.Transformer, error) {
+
+	transformers := []lib.Transformer{}
+
+	if file != "" {
+
+		f, err := os.Open(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+
+		defined, err := registry.Load(f)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		transformers = append(transformers, defined...)
+	}
+
+	for _, spec := range specs {
+
+		transformer, err := registry.Parse(spec)
+
+		if err != nil {
+
+			// Report the stage's position on the command line rather than the
+			// position of 0 that Parse() reports.
+			var definitionError *lib.DefinitionError
+
+			if errors.As(err, &definitionError) {
+				definitionError.Stage = len(transformers)
+			}
+
+			return nil, err
+		}
+
+		transformers = append(transformers, transformer)
+	}
+
+	return transformers, nil
+}
+
+// Apply the given Transformers to each record read from stdin, returning the
+// exit status.
+func process(opts options, transformers []lib.Transformer, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Each record holds a slot from when it is read until it is written, so
+	// that a record that is slow to process stops the reading of others once
+	// the results waiting to be written behind it have taken the rest.
+	window := make(chan struct{}, 2*opts.parallel)
+
+	records := read(ctx, opts.input, stdin, window)
+	results := make(chan record, opts.parallel)
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < opts.parallel; i++ {
+
+		wg.Add(1)
+
+		go func() {
+
+			defer wg.Done()
+
+			for r := range records {
+
+				if r.err == nil {
+					r.value, r.err = lib.ComposeContext(ctx, r.value, transformers...)
+				}
+
+				select {
+				case results <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Close the results channel once every worker has exited.
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return write(opts, cancel, results, window, stdout, stderr)
+}
+
+// Send each record read from stdin, parsed according to the given format, on
+// the returned channel, closing it at the end of the input or when ctx is done.
+// A slot in window is taken for each record before it is sent.
+func read(ctx context.Context, format string, stdin io.Reader, window chan<- struct{}) <-chan record {
+
+	records := make(chan record)
+
+	go func() {
+
+		defer close(records)
+
+		scanner := bufio.NewScanner(stdin)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+		for number := 1; scanner.Scan(); number++ {
+
+			r := record{number: number, value: scanner.Text()}
+
+			if format == "json" {
+
+				var value any
+
+				if err := json.Unmarshal(scanner.Bytes(), &value); err != nil {
+					r.err = fmt.Errorf("invalid JSON: %w", err)
+				}
+
+				r.value = value
+			}
+
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			select {
+			case records <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			select {
+			case records <- record{number: -1, err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return records
+}
+
+// Write the results received on the given channel in the order in which the
+// records were read, returning the exit status. The slot in window taken by
+// each record is released once it has been written.
+func write(
+	opts options,
+	cancel context.CancelFunc,
+	results <-chan record,
+	window <-chan struct{},
+	stdout io.Writer,
+	stderr io.Writer,
+) int {
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+
+	status := 0
+	pending := map[int]record{}
+	next := 1
+
+	// An error reading stdin, which is not associated with any record and is
+	// reported after the records read before it.
+	var readErr error
+
+	for r := range results {
+
+		if r.number < 0 {
+			readErr = r.err
+			continue
+		}
+
+		pending[r.number] = r
+
+		for {
+
+			r, ok := pending[next]
+
+			if !ok {
+				break
+			}
+
+			delete(pending, next)
+			next += 1
+			<-window
+
+			if r.err != nil {
+
+				out.Flush()
+				fmt.Fprintf(stderr, "record %d: %v\n", r.number, r.err)
+				status = 1
+
+				if opts.failFast {
+					cancel()
+					return status
+				}
+
+				continue
+			}
+
+			if err := emit(out, opts.output, r.value); err != nil {
+				fmt.Fprintf(stderr, "record %d: %v\n", r.number, err)
+				status = 1
+			}
+		}
+	}
+
+	if readErr != nil {
+		out.Flush()
+		fmt.Fprintln(stderr, readErr.Error())
+		status = 1
+	}
+
+	return status
+}
+
+// Write a single result in the given format.
+func emit(out io.Writer, format string, value any) error {
+
+	if format == "json" {
+
+		b, err := json.Marshal(value)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	}
+
+	_, err := fmt.Fprintln(out, value)
+	return err
+}
+
+// Prints each record read from stdin, transformed by the pipeline described by
+// the command-line arguments, to stdout.
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,284 @@
+// Copyright Kirk Rader 2024
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+// Run the command with the given stdin and arguments, returning its exit
+// status and what it wrote to stdout and stderr.
+func compose(stdin io.Reader, args ...string) (int, string, string) {
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	status := run(args, stdin, stdout, stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+
+	status, stdout, stderr := compose(strings.NewReader("1\n2\nthree\n"), "int", `add{"n":3}`, `mul{"n":2}`)
+
+	if status != 1 {
+		t.Errorf("expected status 1, got %d", status)
+	}
+
+	if stdout != "8\n10\n" {
+		t.Errorf("expected \"8\\n10\\n\", got %q", stdout)
+	}
+
+	if !strings.HasPrefix(stderr, "record 3: ") || strings.Count(stderr, "\n") != 1 {
+		t.Errorf("expected a single error for record 3, got %q", stderr)
+	}
+
+	status, stdout, stderr = compose(strings.NewReader("1\n2\n"), "int")
+
+	if status != 0 || stdout != "1\n2\n" || stderr != "" {
+		t.Errorf("expected status 0, \"1\\n2\\n\" and no errors, got %d, %q and %q", status, stdout, stderr)
+	}
+}
+
+func TestRunParallel(t *testing.T) {
+
+	input, expected := &strings.Builder{}, &strings.Builder{}
+
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintln(input, i)
+		fmt.Fprintln(expected, i*2)
+	}
+
+	status, stdout, stderr := compose(strings.NewReader(input.String()), "-parallel", "8", "int", `mul{"n":2}`)
+
+	if status != 0 || stderr != "" {
+		t.Errorf("expected status 0 and no errors, got %d and %q", status, stderr)
+	}
+
+	if stdout != expected.String() {
+		t.Errorf("expected the results in the order the records were read")
+	}
+}
+
+func TestRunFailFast(t *testing.T) {
+
+	for _, parallel := range []string{"1", "4"} {
+
+		status, stdout, stderr := compose(
+			strings.NewReader("1\ntwo\n3\nfour\n5\n"),
+			"-fail-fast",
+			"-parallel", parallel,
+			"int")
+
+		if status != 1 {
+			t.Errorf("expected status 1 with -parallel %s, got %d", parallel, status)
+		}
+
+		if stdout != "1\n" {
+			t.Errorf("expected only \"1\\n\" with -parallel %s, got %q", parallel, stdout)
+		}
+
+		if !strings.HasPrefix(stderr, "record 2: ") || strings.Contains(stderr, "record 4") {
+			t.Errorf("expected only record 2's error with -parallel %s, got %q", parallel, stderr)
+		}
+	}
+}
+
+func TestRunJSON(t *testing.T) {
+
+	input := `{"a":1}` + "\n" + `{"b":2}` + "\n" + `{"a":"x"}` + "\n" + "{\n"
+
+	status, stdout, stderr := compose(
+		strings.NewReader(input),
+		"-input", "json",
+		"-output", "json",
+		`field{"name":"a"}`,
+		`get{"name":"a"}`,
+		"string")
+
+	if status != 1 {
+		t.Errorf("expected status 1, got %d", status)
+	}
+
+	if stdout != "\"1\"\n\"x\"\n" {
+		t.Errorf("expected \"\\\"1\\\"\\n\\\"x\\\"\\n\", got %q", stdout)
+	}
+
+	if !strings.Contains(stderr, `record 2: `) || !strings.Contains(stderr, `no field named "a"`) {
+		t.Errorf("expected an error for record 2, got %q", stderr)
+	}
+
+	if !strings.Contains(stderr, "record 4: invalid JSON") {
+		t.Errorf("expected invalid JSON for record 4, got %q", stderr)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+
+	tests := []struct {
+		args   []string
+		stderr string
+	}{
+		{[]string{"-bogus"}, "flag provided but not defined"},
+		{[]string{"-input", "xml"}, `unsupported input format "xml"`},
+		{[]string{"-output", "xml"}, `unsupported output format "xml"`},
+		{[]string{"-describe", "svg"}, `unsupported description format "svg"`},
+		{[]string{"-parallel", "0"}, "-parallel must be at least 1"},
+		{[]string{"int", `add{"n":3}`, "bogus"}, `stage 2 ("bogus")`},
+		{[]string{"int", `add{"n":1.5}`}, `stage 1 ("add"): invalid parameters`},
+		{[]string{"int", `mul{"n":2.9}`}, `stage 1 ("mul"): invalid parameters`},
+		{[]string{"-f", "no such file.json"}, "no such file"},
+	}
+
+	for _, test := range tests {
+
+		status, _, stderr := compose(strings.NewReader(""), test.args...)
+
+		if status != 2 {
+			t.Errorf("expected status 2 for %v, got %d", test.args, status)
+		}
+
+		if !strings.Contains(stderr, test.stderr) {
+			t.Errorf("expected %q for %v, got %q", test.stderr, test.args, stderr)
+		}
+	}
+}
+
+func TestRunListAndDescribe(t *testing.T) {
+
+	status, stdout, _ := compose(strings.NewReader(""), "-list")
+
+	if status != 0 || !strings.Contains(stdout, "add\n") || !strings.Contains(stdout, "expr\n") {
+		t.Errorf("expected status 0 and the built-in names, got %d and %q", status, stdout)
+	}
+
+	status, stdout, _ = compose(strings.NewReader(""), "-describe", "text", "int", `add{"n":3}`)
+
+	if status != 0 || !strings.HasPrefix(stdout, "Compose\n  0: int\n  1: add") {
+		t.Errorf("expected status 0 and a description, got %d and %q", status, stdout)
+	}
+
+	status, stdout, _ = compose(strings.NewReader(""), "-describe", "dot", "int")
+
+	if status != 0 || !strings.HasPrefix(stdout, "digraph {") {
+		t.Errorf("expected status 0 and a DOT graph, got %d and %q", status, stdout)
+	}
+}
+
+// Reader that returns its content and then fails.
+type failingReader struct {
+	content io.Reader
+}
+
+// Implement io.Reader.
+func (r failingReader) Read(p []byte) (int, error) {
+
+	n, err := r.content.Read(p)
+
+	if err == io.EOF {
+		return n, errors.New("stdin went away")
+	}
+
+	return n, err
+}
+
+func TestRunReadError(t *testing.T) {
+
+	for _, parallel := range []string{"1", "4"} {
+
+		stdin := failingReader{strings.NewReader("1\n2\n3\n")}
+		status, stdout, stderr := compose(stdin, "-parallel", parallel, "int", `add{"n":1}`)
+
+		if status != 1 {
+			t.Errorf("expected status 1 with -parallel %s, got %d", parallel, status)
+		}
+
+		if stdout != "2\n3\n4\n" {
+			t.Errorf("expected every record read before the error with -parallel %s, got %q", parallel, stdout)
+		}
+
+		if stderr != "stdin went away\n" {
+			t.Errorf("expected the read error with -parallel %s, got %q", parallel, stderr)
+		}
+	}
+}
+
+func TestWriteReadErrorFirst(t *testing.T) {
+
+	// Records can finish being processed after a read error that followed
+	// them, e.g. when -parallel is greater than 1.
+	results := make(chan record, 3)
+	results <- record{number: 2, value: 20}
+	results <- record{number: -1, err: errors.New("stdin went away")}
+	results <- record{number: 1, value: 10}
+	close(results)
+
+	// The slots taken by the two records when they were read.
+	window := make(chan struct{}, 2)
+	window <- struct{}{}
+	window <- struct{}{}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	status := write(options{output: "text"}, func() {}, results, window, stdout, stderr)
+
+	if status != 1 || stdout.String() != "10\n20\n" || stderr.String() != "stdin went away\n" {
+		t.Errorf(
+			"expected status 1, \"10\\n20\\n\" and the read error, got %d, %q and %q",
+			status,
+			stdout.String(),
+			stderr.String())
+	}
+}
+
+func TestProcessSlowRecord(t *testing.T) {
+
+	release := make(chan struct{})
+	var processed atomic.Int32
+
+	// Record 1 is stuck until released, while the others are processed as
+	// quickly as possible.
+	slow := lib.MakeTransformer(func(value string) string {
+
+		if value == "1" {
+			<-release
+		} else {
+			processed.Add(1)
+		}
+
+		return value
+	})
+
+	input := &strings.Builder{}
+
+	for i := 1; i <= 1000; i++ {
+		fmt.Fprintln(input, i)
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	done := make(chan int)
+	opts := options{input: "text", output: "text", parallel: 4}
+
+	go func() {
+		done <- process(opts, []lib.Transformer{slow}, strings.NewReader(input.String()), stdout, stderr)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Every record read takes a slot until it is written, including record 1.
+	if n := processed.Load(); n >= 2*int32(opts.parallel) {
+		t.Errorf("expected fewer than %d records to be read ahead of record 1, got %d", 2*opts.parallel, n)
+	}
+
+	close(release)
+
+	if status := <-done; status != 0 || stdout.String() != input.String() {
+		t.Errorf("expected status 0 and every record in order, got %d and %d bytes", status, stdout.Len())
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Configures the tumbling windows created by Window().
+type WindowOptions struct {
+
+	// How long a window stays open after receiving its first value.
+	Duration time.Duration
+
+	// Maximum number of values in a window, or zero for no limit. A window is
+	// closed early when it is full.
+	MaxSize int
+
+	// Clock used to time windows, or nil for SystemClock.
+	Clock Clock
+}
+
+// Send value on out unless ctx is done first, returning true if and only if it
+// was sent.
+func emit(ctx context.Context, out chan<- any, value any) bool {
+
+	select {
+	case out <- value:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Return a channel on which the values received from in are sent in batches,
+// each a []any of the given size, for use with ComposeStream(). Sizes less
+// than 1 are treated as 1.
+//
+// When in is closed, any values received since the last full batch are sent as
+// a final, smaller batch. Like worker() in ../../10_concurrency/concurrency.go,
+// the goroutine that sends the batches closes the returned channel when it
+// exits, which happens when in is closed and drained or ctx is done. A partial
+// batch is discarded when ctx is done.
+func Batch(ctx context.Context, in <-chan any, size int) <-chan any {
+	return Window(ctx, in, WindowOptions{MaxSize: max(size, 1)})
+}
+
+// Return a channel on which the values received from in are sent in tumbling
+// windows, each a []any, for use with ComposeStream().
+//
+// A window opens when it receives its first value and closes, causing it to be
+// sent, when options.Duration has elapsed or it holds options.MaxSize values,
+// whichever happens first. Windows therefore never overlap and are never
+// empty. A Duration of zero or less means that windows are only closed when
+// they are full, as by Batch().
+//
+// When in is closed, the window that is open, if any, is sent without waiting
+// for it to close. The returned channel is closed as described for Batch().
+func Window(ctx context.Context, in <-chan any, options WindowOptions) <-chan any {
+
+	clock := options.Clock
+
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	out := make(chan any)
+
+	go func() {
+
+		defer close(out)
+
+		window := []any{}
+
+		// Receives when the open window's time is up; nil, and so never ready,
+		// while no window is open or windows are not timed.
+		var expired <-chan time.Time
+
+		for {
+
+			select {
+
+			case value, ok := <-in:
+				if !ok {
+					if len(window) > 0 {
+						emit(ctx, out, window)
+					}
+					return
+				}
+
+				if len(window) == 0 && options.Duration > 0 {
+					expired = clock.After(options.Duration)
+				}
+
+				window = append(window, value)
+
+				if options.MaxSize > 0 && len(window) >= options.MaxSize {
+					if !emit(ctx, out, window) {
+						return
+					}
+					window, expired = []any{}, nil
+				}
+
+			case <-expired:
+				if !emit(ctx, out, window) {
+					return
+				}
+				window, expired = []any{}, nil
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Return a channel on which the values received from in are sent in sliding
+// windows, each a []any holding the given number of consecutive values, for
+// use with ComposeStream(). Each window starts the given number of values
+// after the one before it, so windows overlap when step is less than size and
+// values are skipped when step is greater than size. Sizes and steps less than
+// 1 are treated as 1.
+//
+// For example, with a size of 3 and a step of 1, the values 1, 2, 3, 4 are sent
+// as [1 2 3] and [2 3 4].
+//
+// When in is closed, the values received since the last window was sent, if
+// any, are sent along with the values that precede them in their window as a
+// final, smaller window. The returned channel is closed as described for
+// Batch().
+func Slide(ctx context.Context, in <-chan any, size int, step int) <-chan any {
+
+	size, step = max(size, 1), max(step, 1)
+	out := make(chan any)
+
+	go func() {
+
+		defer close(out)
+
+		window := []any{}
+
+		// Number of values in window not yet sent as part of any window.
+		fresh := 0
+
+		// Number of values to discard before the next window starts, when
+		// step is greater than size.
+		skip := 0
+
+		for {
+
+			select {
+
+			case value, ok := <-in:
+				if !ok {
+					if fresh > 0 {
+						emit(ctx, out, window)
+					}
+					return
+				}
+
+				if skip > 0 {
+					skip -= 1
+					continue
+				}
+
+				window = append(window, value)
+				fresh += 1
+
+				if len(window) < size {
+					continue
+				}
+
+				if !emit(ctx, out, slices.Clone(window)) {
+					return
+				}
+
+				fresh = 0
+
+				if step >= size {
+					window, skip = []any{}, step-size
+				} else {
+					window = slices.Clone(window[step:])
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// Return a channel on which each element of each []any received from in is
+// sent individually, reversing the effect of Batch(), Window() or Slide() with
+// a step equal to its size. Values that are not of type []any are sent
+// unchanged.
+//
+// The returned channel is closed as described for Batch().
+func Unbatch(ctx context.Context, in <-chan any) <-chan any {
+
+	out := make(chan any)
+
+	go func() {
+
+		defer close(out)
+
+		for {
+
+			select {
+
+			case value, ok := <-in:
+				if !ok {
+					return
+				}
+
+				batch, isBatch := value.([]any)
+
+				if !isBatch {
+					batch = []any{value}
+				}
+
+				for _, element := range batch {
+					if !emit(ctx, out, element) {
+						return
+					}
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,170 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Clock whose timers only fire when the test sends on them. Each channel
+// returned by After() is also sent on requests, so that the test can wait for
+// a timer to have been started before firing it.
+type manualClock struct {
+	requests chan chan time.Time
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{requests: make(chan chan time.Time, 16)}
+}
+
+func (c *manualClock) Now() time.Time {
+	return time.Time{}
+}
+
+func (c *manualClock) After(time.Duration) <-chan time.Time {
+
+	timer := make(chan time.Time, 1)
+	c.requests <- timer
+	return timer
+}
+
+// Receive every value from out until it is closed.
+func collect(out <-chan any) []any {
+
+	values := []any{}
+
+	for value := range out {
+		values = append(values, value)
+	}
+
+	return values
+}
+
+func TestBatch(t *testing.T) {
+
+	ctx := context.Background()
+	batches := collect(Batch(ctx, produce(1, 2, 3, 4, 5), 2))
+	expected := []any{[]any{1, 2}, []any{3, 4}, []any{5}}
+
+	if !reflect.DeepEqual(batches, expected) {
+		t.Errorf("expected %v, got %v", expected, batches)
+	}
+
+	if batches := collect(Batch(ctx, produce(), 2)); len(batches) != 0 {
+		t.Errorf("expected no batches, got %v", batches)
+	}
+
+	values := collect(Unbatch(ctx, Batch(ctx, produce(1, 2, 3, 4, 5), 2)))
+
+	if !reflect.DeepEqual(values, []any{1, 2, 3, 4, 5}) {
+		t.Errorf("expected [1 2 3 4 5], got %v", values)
+	}
+}
+
+func TestWindow(t *testing.T) {
+
+	clock := newManualClock()
+	in := make(chan any)
+	out := Window(context.Background(), in, WindowOptions{Duration: time.Second, MaxSize: 3, Clock: clock})
+
+	// The first window closes when its time is up.
+	in <- 1
+	in <- 2
+	(<-clock.requests) <- time.Time{}
+
+	if window := <-out; !reflect.DeepEqual(window, []any{1, 2}) {
+		t.Errorf("expected [1 2], got %v", window)
+	}
+
+	// The second closes when it is full, and the third when in is closed.
+	in <- 3
+	in <- 4
+	in <- 5
+
+	if window := <-out; !reflect.DeepEqual(window, []any{3, 4, 5}) {
+		t.Errorf("expected [3 4 5], got %v", window)
+	}
+
+	in <- 6
+	in <- 7
+	close(in)
+
+	if windows := collect(out); !reflect.DeepEqual(windows, []any{[]any{6, 7}}) {
+		t.Errorf("expected [[6 7]], got %v", windows)
+	}
+
+	// Each window started its own timer.
+	if len(clock.requests) != 2 {
+		t.Errorf("expected 2 more timers, got %d", len(clock.requests))
+	}
+}
+
+func TestSlide(t *testing.T) {
+
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		size, step int
+		expected   []any
+	}{
+		{3, 1, []any{[]any{1, 2, 3}, []any{2, 3, 4}, []any{3, 4, 5}}},
+		{2, 2, []any{[]any{1, 2}, []any{3, 4}, []any{5}}},
+		{2, 3, []any{[]any{1, 2}, []any{4, 5}}},
+		{4, 3, []any{[]any{1, 2, 3, 4}, []any{4, 5}}},
+		{9, 1, []any{[]any{1, 2, 3, 4, 5}}},
+	} {
+
+		windows := collect(Slide(ctx, produce(1, 2, 3, 4, 5), test.size, test.step))
+
+		if !reflect.DeepEqual(windows, test.expected) {
+			t.Errorf("size %d, step %d: expected %v, got %v", test.size, test.step, test.expected, windows)
+		}
+	}
+}
+
+func TestBatchCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan any)
+	out := Batch(ctx, in, 10)
+
+	in <- 1
+	cancel()
+
+	// The partial batch is discarded and the channel closed.
+	if batches := collect(out); len(batches) != 0 {
+		t.Errorf("expected no batches, got %v", batches)
+	}
+}
+
+func TestBatchStream(t *testing.T) {
+
+	ctx := context.Background()
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	sum := MakeMapper(func(batch []any) int {
+
+		total := 0
+
+		for _, value := range batch {
+			total += value.(int)
+		}
+
+		return total
+	})
+
+	out, errs := ComposeStream(ctx, produce(1, 2, 3, 4, 5), add)
+	out, errs2 := ComposeStream(ctx, Batch(ctx, out, 2), sum)
+	results, _ := drain(out, errs2)
+
+	for err := range errs {
+		t.Errorf("expected no errors, got %v", err)
+	}
+
+	if !reflect.DeepEqual(results, []any{5, 9, 6}) {
+		t.Errorf("expected [5 9 6], got %v", results)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"slices"
+)
+
+// The Transformer that returns its argument unchanged, which is the identity
+// element of composition: inserting it anywhere in a chain of Transformers
+// makes no difference to the result. It is also what Chain() returns when
+// given no Transformers.
+var Identity Transformer = identity()
+
+// Return the stage-backed Transformer for Identity.
+func identity() Transformer {
+
+	s := &stage{
+		run: func(_ context.Context, value any) (any, error) {
+			return value, nil
+		},
+		name: "Identity",
+	}
+
+	return s.transformer()
+}
+
+// Return a Transformer that applies the given Transformers in the manner of
+// ComposeContext(), i.e. the point-free composition of the given
+// Transformers, so that
+//
+//	lib.Compose(x, a, lib.Chain(b, c), d)
+//
+// returns the same result as
+//
+//	lib.Compose(x, a, b, c, d)
+//
+// The returned Transformer can be stored, passed around and nested inside
+// other chains and combinators to any depth. When one of its Transformers
+// fails, the ComposeError reported for the chain's own step has as its Err
+// another ComposeError whose Step identifies that Transformer, so the error's
+// Path() and message report the whole path to the step that failed, e.g.
+// "step 2 → step 1 → step 0".
+//
+// A chain behaves like a single step to the pipeline that contains it: its
+// Transformers are not seen by that pipeline's Observers or Validate(), and if
+// a Transformer created by MakeCompensable() inside it completes, it is only
+// compensated if a later Transformer in the same chain fails, not if a later
+// step of the enclosing pipeline does.
+//
+// The returned Transformer accepts the type accepted by the first of the
+// given Transformers and returns the type returned by the last, as far as
+// Validate() is concerned, where they are known.
+func Chain(transformers ...Transformer) Transformer {
+
+	if len(transformers) == 0 {
+		return Identity
+	}
+
+	transformers = slices.Clone(transformers)
+
+	s := &stage{
+		run: func(ctx context.Context, value any) (any, error) {
+
+			result, _, err := defaultComposer.compose(ctx, value, transformers, 0, false)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return result, nil
+		},
+		name: "Chain",
+		children: func() []Description {
+			return describeSteps(transformers...)
+		},
+	}
+
+	if first := inspect(transformers[0]); first != nil {
+		s.in = first.in
+	}
+
+	if last := inspect(transformers[len(transformers)-1]); last != nil {
+		s.out = last.out
+	}
+
+	return s.transformer()
+}
@@ -0,0 +1,127 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	double := MakeTransformer(func(value int) int { return value * 2 })
+
+	expected, _ := Compose(1, add, double, add, double)
+	chain := Chain(add, double)
+
+	for _, transformers := range [][]Transformer{
+		{chain, chain},
+		{add, Chain(double, add), double},
+		{Chain(Chain(add), Chain(double, Chain(add, double)))},
+		{Identity, chain, Identity, Chain(), chain},
+	} {
+
+		result, err := Compose(1, transformers...)
+
+		if err != nil || result != expected {
+			t.Errorf("expected %v and no error, got %v and %v", expected, result, err)
+		}
+	}
+
+	if result, err := Compose("x", Chain()); err != nil || result != "x" {
+		t.Errorf("expected x and no error, got %v and %v", result, err)
+	}
+}
+
+func TestChainPath(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	fail := MakeTransformer(func(value int) int {
+		panic("deep")
+	})
+
+	inner := Chain(fail, add)
+	middle := Chain(add, inner)
+	_, err := Compose(0, add, add, middle, add)
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v", err)
+	}
+
+	if path := composeError.Path(); !reflect.DeepEqual(path, []int{2, 1, 0}) {
+		t.Errorf("expected path [2 1 0], got %v", path)
+	}
+
+	message := err.Error()
+
+	if !strings.Contains(message, "step 2 → step 1 → step 0 (3 of type int): deep") {
+		t.Errorf("expected the message to report the path, got %s", message)
+	}
+
+	if path := (&ComposeError{Step: 4}).Path(); !reflect.DeepEqual(path, []int{4}) {
+		t.Errorf("expected path [4], got %v", path)
+	}
+}
+
+func TestChainTypes(t *testing.T) {
+
+	parse := MakeMapper(func(value string) int { return len(value) })
+	half := MakeMapper(func(value int) float64 { return float64(value) / 2 })
+	chain := Chain(parse, half)
+
+	if err := Validate("abc", chain, half); err == nil {
+		t.Errorf("expected a *TypeError since chain returns float64")
+	}
+
+	if err := Validate("abc", chain); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if text := Describe(chain).String(); !strings.Contains(text, "0: Chain (func(string) float64)\n    0: func(string) int\n") {
+		t.Errorf("expected the chain's steps to be described, got\n%s", text)
+	}
+}
+
+func TestChainCompensation(t *testing.T) {
+
+	undone := []int{}
+
+	step := func(n int) Transformer {
+		return MakeCompensable(
+			func(value int) (int, error) { return value + n, nil },
+			func(output int) error {
+				undone = append(undone, n)
+				return nil
+			})
+	}
+
+	fail := MakeFallibleTransformer(func(value int) (int, error) { return 0, errors.New("failed") })
+
+	// The chain's failure is a failure of the enclosing pipeline's step 1, so
+	// step 0 is also compensated.
+	if _, err := Compose(0, step(1), Chain(step(2), step(3), fail)); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if !reflect.DeepEqual(undone, []int{3, 2, 1}) {
+		t.Errorf("expected [3 2 1] to have been undone, got %v", undone)
+	}
+
+	// Steps inside a chain that completed are not compensated when a later step
+	// of the enclosing pipeline fails.
+	undone = []int{}
+
+	if _, err := Compose(0, Chain(step(2), step(3)), step(1), fail); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if !reflect.DeepEqual(undone, []int{1}) {
+		t.Errorf("expected [1] to have been undone, got %v", undone)
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+// Returned, wrapped, by CheckpointStore.Load() when there is no checkpoint for
+// the given key.
+var ErrNoCheckpoint = errors.New("no checkpoint")
+
+// Persists the intermediate values of a Composer configured with Checkpoint().
+type CheckpointStore interface {
+
+	// Record that the given number of steps of the run identified by key have
+	// completed, producing the value encoded as data, replacing any previous
+	// checkpoint for that key.
+	Save(key string, completed int, data []byte) error
+
+	// Return the most recent checkpoint saved for key, or an error wrapping
+	// ErrNoCheckpoint if there is none.
+	Load(key string) (completed int, data []byte, err error)
+
+	// Discard the checkpoint for key, if any.
+	Delete(key string) error
+}
+
+// Converts values to and from the bytes kept by a CheckpointStore.
+type Codec interface {
+
+	// Return the encoding of value.
+	Marshal(value any) ([]byte, error)
+
+	// Return the value encoded as data. The type is that accepted by the next
+	// step, if known, or nil.
+	Unmarshal(data []byte, typ reflect.Type) (any, error)
+}
+
+// Codec using encoding/json.
+type jsonCodec struct{}
+
+// Implement Codec.Marshal().
+func (jsonCodec) Marshal(value any) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Implement Codec.Unmarshal().
+func (jsonCodec) Unmarshal(data []byte, typ reflect.Type) (any, error) {
+
+	if typ == nil {
+		typ = reflect.TypeFor[any]()
+	}
+
+	pointer := reflect.New(typ)
+
+	if err := json.Unmarshal(data, pointer.Interface()); err != nil {
+		return nil, err
+	}
+
+	return pointer.Elem().Interface(), nil
+}
+
+// Codec using encoding/json.
+//
+// JSON does not record Go types, so a value is decoded as the type accepted by
+// the step that consumes it when that is known, e.g. for a Transformer created
+// by MakeMapper(). Otherwise it is decoded as by json.Unmarshal() into an any,
+// so that numbers become float64, objects map[string]any and so on.
+var JSONCodec Codec = jsonCodec{}
+
+// Codec using encoding/gob.
+type gobCodec struct{}
+
+// Implement Codec.Marshal().
+func (gobCodec) Marshal(value any) ([]byte, error) {
+
+	buffer := &bytes.Buffer{}
+
+	if err := gob.NewEncoder(buffer).Encode(&value); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Implement Codec.Unmarshal().
+func (gobCodec) Unmarshal(data []byte, _ reflect.Type) (any, error) {
+
+	var value any
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// Codec using encoding/gob.
+//
+// Values are encoded along with their dynamic types, so they are decoded as
+// exactly the type that was saved. As with any value that gob encodes as an
+// interface, types other than the predeclared ones must be passed to
+// gob.Register() before they are saved or loaded.
+var GobCodec Codec = gobCodec{}
+
+// CheckpointStore that keeps each checkpoint in its own file in Dir, which
+// must already exist.
+//
+// A checkpoint is written to a temporary file which is then renamed, so a
+// crash while saving leaves the previous checkpoint intact.
+type DirStore struct {
+	Dir string
+}
+
+// Return the name of the file in which the checkpoint for key is kept.
+func (d DirStore) path(key string) string {
+	return filepath.Join(d.Dir, url.PathEscape(key)+".checkpoint")
+}
+
+// Implement CheckpointStore.Save().
+//
+// The file contains the number of completed steps on a line of its own,
+// followed by the encoded value.
+func (d DirStore) Save(key string, completed int, data []byte) error {
+
+	file, err := os.CreateTemp(d.Dir, ".checkpoint-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	content := append([]byte(strconv.Itoa(completed)+"\n"), data...)
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), d.path(key))
+}
+
+// Implement CheckpointStore.Load().
+func (d DirStore) Load(key string) (int, []byte, error) {
+
+	content, err := os.ReadFile(d.path(key))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil, fmt.Errorf("%w for %q", ErrNoCheckpoint, key)
+	}
+
+	if err != nil {
+		return 0, nil, err
+	}
+
+	line, data, ok := bytes.Cut(content, []byte("\n"))
+
+	if !ok {
+		return 0, nil, fmt.Errorf("malformed checkpoint for %q", key)
+	}
+
+	completed, err := strconv.Atoi(string(line))
+
+	if err != nil {
+		return 0, nil, fmt.Errorf("malformed checkpoint for %q: %w", key, err)
+	}
+
+	return completed, data, nil
+}
+
+// Implement CheckpointStore.Delete().
+func (d DirStore) Delete(key string) error {
+
+	err := os.Remove(d.path(key))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// CheckpointStore that keeps checkpoints in memory, e.g. for testing. Create
+// using NewMemoryStore().
+type MemoryStore struct {
+	mutex       sync.Mutex
+	checkpoints map[string]memoryCheckpoint
+}
+
+// A checkpoint kept by a MemoryStore.
+type memoryCheckpoint struct {
+	completed int
+	data      []byte
+}
+
+// Return an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{checkpoints: map[string]memoryCheckpoint{}}
+}
+
+// Implement CheckpointStore.Save().
+func (m *MemoryStore) Save(key string, completed int, data []byte) error {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.checkpoints[key] = memoryCheckpoint{completed: completed, data: bytes.Clone(data)}
+	return nil
+}
+
+// Implement CheckpointStore.Load().
+func (m *MemoryStore) Load(key string) (int, []byte, error) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	checkpoint, ok := m.checkpoints[key]
+
+	if !ok {
+		return 0, nil, fmt.Errorf("%w for %q", ErrNoCheckpoint, key)
+	}
+
+	return checkpoint.completed, bytes.Clone(checkpoint.data), nil
+}
+
+// Implement CheckpointStore.Delete().
+func (m *MemoryStore) Delete(key string) error {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.checkpoints, key)
+	return nil
+}
+
+// Saves and restores the checkpoints of a Composer.
+type checkpointer struct {
+	store CheckpointStore
+	codec Codec
+	key   string
+}
+
+// Cause the Composer to save the value produced by each step except the last
+// to the given store, encoded by the given codec, under the given key, which
+// identifies the run, and to delete it when the last step succeeds.
+//
+// When the Composer starts a run for which a checkpoint was saved, e.g. by a
+// process that crashed, the steps it records as completed are skipped and the
+// saved value is passed to the next one in place of the initial value. The
+// chain of Transformers must be the same as for the run that saved it.
+//
+// A checkpoint is left in place when a step fails, so that the run can be
+// continued later. Steps created by MakeCompensable() are therefore only
+// compensated if they completed since the last checkpoint was saved, since
+// those are the only ones that continuing from it runs again.
+//
+// Failure to save a checkpoint also stops the run, since continuing without one
+// would defeat its purpose. It is reported as a *ComposeError for the step
+// whose result could not be saved and that step is compensated as if it had
+// failed, so that the last checkpoint saved, if any, still records where to
+// continue. When the Composer is used by ComposeResumable(), it is instead
+// reported for the step that would have run next, as recorded by the Result.
+//
+// Checkpoints are not saved by ComposeStream(), whose values are not the
+// results of a single run.
+func Checkpoint(store CheckpointStore, codec Codec, key string) Option {
+
+	return func(c *Composer) {
+		c.checkpoint = &checkpointer{store: store, codec: codec, key: key}
+	}
+}
+
+// Persist the value produced by the given number of completed steps.
+func (c *checkpointer) save(completed int, value any) error {
+
+	data, err := c.codec.Marshal(value)
+
+	if err == nil {
+		err = c.store.Save(c.key, completed, data)
+	}
+
+	if err != nil {
+		return fmt.Errorf("saving checkpoint %q after Transformer %d: %w", c.key, completed-1, err)
+	}
+
+	return nil
+}
+
+// Return the saved value and number of completed steps of the given chain, or
+// zero steps if there is no checkpoint.
+func (c *checkpointer) restore(transformers []Transformer) (any, int, error) {
+
+	completed, data, err := c.store.Load(c.key)
+
+	if errors.Is(err, ErrNoCheckpoint) {
+		return nil, 0, nil
+	}
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("loading checkpoint %q: %w", c.key, err)
+	}
+
+	if completed < 0 || completed > len(transformers) {
+		return nil, 0, fmt.Errorf(
+			"checkpoint %q records %d completed steps of %d",
+			c.key,
+			completed,
+			len(transformers))
+	}
+
+	// The value is passed to the next step, or is the final result of the last
+	// one.
+	var typ reflect.Type
+
+	if completed < len(transformers) {
+		if s := inspect(transformers[completed]); s != nil {
+			typ = s.in
+		}
+	}
+
+	if typ == nil && completed > 0 {
+		if s := inspect(transformers[completed-1]); s != nil {
+			typ = s.out
+		}
+	}
+
+	value, err := c.codec.Unmarshal(data, typ)
+
+	if err != nil {
+		return nil, 0, fmt.Errorf("decoding checkpoint %q: %w", c.key, err)
+	}
+
+	return value, completed, nil
+}
+
+// Discard the checkpoint once the run has succeeded.
+func (c *checkpointer) clear() error {
+
+	if err := c.store.Delete(c.key); err != nil {
+		return fmt.Errorf("deleting checkpoint %q: %w", c.key, err)
+	}
+
+	return nil
+}
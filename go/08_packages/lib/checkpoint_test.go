@@ -0,0 +1,236 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A chain of three steps whose second one fails while *broken is true, and the
+// number of times the first one was invoked.
+func checkpointed() ([]Transformer, *bool, *int) {
+
+	broken, calls := true, 0
+
+	transformers := []Transformer{
+		MakeTransformer(func(value int) int {
+			calls += 1
+			return value + 1
+		}),
+		MakeFallibleTransformer(func(value int) (int, error) {
+
+			if broken {
+				return 0, errors.New("crashed")
+			}
+
+			return value * 10, nil
+		}),
+		MakeTransformer(func(value int) int { return value + 2 }),
+	}
+
+	return transformers, &broken, &calls
+}
+
+func TestCheckpoint(t *testing.T) {
+
+	for name, test := range map[string]struct {
+		store CheckpointStore
+		codec Codec
+	}{
+		"dir json":    {DirStore{Dir: t.TempDir()}, JSONCodec},
+		"dir gob":     {DirStore{Dir: t.TempDir()}, GobCodec},
+		"memory json": {NewMemoryStore(), JSONCodec},
+	} {
+
+		transformers, broken, calls := checkpointed()
+
+		// A separate Composer stands in for each run of the batch job.
+		composer := NewComposer(Checkpoint(test.store, test.codec, "job/1"))
+
+		if _, err := composer.Compose(1, transformers...); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+
+		completed, _, err := test.store.Load("job/1")
+
+		if err != nil || completed != 1 {
+			t.Errorf("%s: expected a checkpoint after 1 step, got %d and %v", name, completed, err)
+		}
+
+		*broken = false
+		composer = NewComposer(Checkpoint(test.store, test.codec, "job/1"))
+
+		// The initial value is ignored in favor of the checkpoint.
+		result, err := composer.Compose(100, transformers...)
+
+		if err != nil || result != 22 {
+			t.Errorf("%s: expected 22 and no error, got %v and %v", name, result, err)
+		}
+
+		if *calls != 1 {
+			t.Errorf("%s: expected the first step to have been skipped, got %d calls", name, *calls)
+		}
+
+		if _, _, err := test.store.Load("job/1"); !errors.Is(err, ErrNoCheckpoint) {
+			t.Errorf("%s: expected the checkpoint to have been deleted, got %v", name, err)
+		}
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+
+	store := NewMemoryStore()
+	transformers, broken, _ := checkpointed()
+	composer := NewComposer(Checkpoint(store, JSONCodec, "job"))
+	result := composer.ComposeResumable(context.Background(), 1, transformers...)
+
+	if result.Completed != 1 || result.Value != 2 {
+		t.Fatalf("expected 2 after 1 step, got %+v", result)
+	}
+
+	*broken = false
+	result = result.Resume(context.Background(), nil)
+
+	if result.Err != nil || result.Value != 22 {
+		t.Errorf("expected 22 and no error, got %+v", result)
+	}
+
+	if _, _, err := store.Load("job"); !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("expected the checkpoint to have been deleted, got %v", err)
+	}
+}
+
+func TestCheckpointMismatch(t *testing.T) {
+
+	store := NewMemoryStore()
+	store.Save("job", 5, []byte("1"))
+	transformers, _, _ := checkpointed()
+
+	if _, err := NewComposer(Checkpoint(store, JSONCodec, "job")).Compose(1, transformers...); err == nil {
+		t.Errorf("expected an error for a checkpoint of a longer chain")
+	}
+}
+
+func TestCodecs(t *testing.T) {
+
+	for name, codec := range map[string]Codec{"json": JSONCodec, "gob": GobCodec} {
+
+		data, err := codec.Marshal([]string{"a", "b"})
+
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		value, err := codec.Unmarshal(data, reflect.TypeFor[[]string]())
+
+		if err != nil || !reflect.DeepEqual(value, []string{"a", "b"}) {
+			t.Errorf("%s: expected [a b] and no error, got %#v and %v", name, value, err)
+		}
+	}
+
+	// Without a type, JSON numbers are decoded as float64.
+	value, err := JSONCodec.Unmarshal([]byte("42"), nil)
+
+	if err != nil || value != 42.0 {
+		t.Errorf("expected 42.0 and no error, got %#v and %v", value, err)
+	}
+}
+
+// CheckpointStore whose Save() always fails.
+type fullStore struct {
+	*MemoryStore
+}
+
+var errDiskFull = errors.New("disk full")
+
+// Implement CheckpointStore.Save().
+func (fullStore) Save(string, int, []byte) error {
+	return errDiskFull
+}
+
+func TestCheckpointSaveFailure(t *testing.T) {
+
+	undone := []int{}
+
+	reserve := MakeCompensable(
+		func(value int) (int, error) { return value + 1, nil },
+		func(value int) error {
+			undone = append(undone, value)
+			return nil
+		})
+
+	double := MakeTransformer(func(value int) int { return value * 2 })
+	c := NewComposer(Checkpoint(fullStore{NewMemoryStore()}, JSONCodec, "job"))
+
+	_, err := c.Compose(1, reserve, double)
+
+	if !errors.Is(err, errDiskFull) {
+		t.Fatalf("expected errDiskFull, got %v", err)
+	}
+
+	// With no checkpoint saved, starting over runs step 0 again, so it is
+	// treated as having failed.
+	composeError, ok := err.(*ComposeError)
+
+	if !ok || composeError.Step != 0 || composeError.Value != 1 {
+		t.Errorf("expected a *ComposeError at step 0 with value 1, got %v", err)
+	}
+
+	if len(undone) != 1 || undone[0] != 2 {
+		t.Errorf("expected the completed step to have been compensated with 2, got %v", undone)
+	}
+
+	r := NewComposer(Checkpoint(fullStore{NewMemoryStore()}, JSONCodec, "job")).
+		ComposeResumable(context.Background(), 1, reserve, double)
+
+	if r.Completed != 1 || r.Value != 2 || !errors.Is(r.Err, errDiskFull) {
+		t.Errorf("expected 1 step completed with 2 and errDiskFull, got %d, %v and %v", r.Completed, r.Value, r.Err)
+	}
+
+	if len(undone) != 1 {
+		t.Errorf("expected a resumable run not to be compensated, got %v", undone)
+	}
+}
+
+func TestCheckpointCompensable(t *testing.T) {
+
+	log := []string{}
+	broken := true
+
+	fail := MakeFallibleTransformer(func(value int) (int, error) {
+
+		if broken {
+			return 0, errors.New("crashed")
+		}
+
+		return value * 10, nil
+	})
+
+	transformers := []Transformer{provision("a", &log), provision("b", &log), fail}
+	store := NewMemoryStore()
+
+	if _, err := NewComposer(Checkpoint(store, JSONCodec, "job")).Compose(0, transformers...); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// Both steps are recorded by the checkpoint, so undoing them would lose
+	// their effects when the run is continued.
+	if strings.Join(log, ",") != "+a,+b" {
+		t.Errorf("expected nothing to be compensated, got %v", log)
+	}
+
+	broken = false
+	result, err := NewComposer(Checkpoint(store, JSONCodec, "job")).Compose(0, transformers...)
+
+	if err != nil || result != 20 {
+		t.Errorf("expected 20 and no error, got %v and %v", result, err)
+	}
+
+	if strings.Join(log, ",") != "+a,+b" {
+		t.Errorf("expected the completed steps to be skipped, got %v", log)
+	}
+}
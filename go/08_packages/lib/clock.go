@@ -0,0 +1,37 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"time"
+)
+
+// Source of time for Transformers that wait or measure elapsed time.
+//
+// Tests can substitute an implementation that does not actually wait, so that
+// they run instantly and deterministically.
+type Clock interface {
+
+	// Return the current time, like time.Now().
+	Now() time.Time
+
+	// Return a channel on which the current time will be sent after the given
+	// duration, like time.After().
+	After(d time.Duration) <-chan time.Time
+}
+
+// Clock backed by the time package.
+type systemClock struct{}
+
+// Implement Clock.Now().
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Implement Clock.After().
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// The Clock used when none is specified.
+var SystemClock Clock = systemClock{}
@@ -0,0 +1,58 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Clock whose time only advances when After() is called, which returns a
+// channel that is ready immediately. Records the duration of each call to
+// After().
+type fakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	waited []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	c.waited = append(c.waited, d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// Advance the clock without waiting.
+func (c *fakeClock) Advance(d time.Duration) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestSystemClock(t *testing.T) {
+
+	before := time.Now()
+	after := <-SystemClock.After(time.Millisecond)
+
+	if after.Before(before) || SystemClock.Now().Before(after) {
+		t.Errorf("expected time to advance")
+	}
+}
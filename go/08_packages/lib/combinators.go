@@ -0,0 +1,205 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Returned, wrapped, by a Transformer created by Switch() when no case matches
+// and there is no default.
+var ErrNoCase = errors.New("no matching case")
+
+// Returned, wrapped, by a Transformer created by Until() when its predicate is
+// not satisfied within the maximum number of iterations.
+var ErrIterationLimit = errors.New("iteration limit exceeded")
+
+// The Transformers returned by the functions in this file are ordinary
+// Transformers that can be passed to Compose() and nested inside one another.
+// Each of them invokes its inner Transformers the way Compose() does, so when
+// an inner Transformer fails, the ComposeError reported for the combinator's
+// own step has as its Err another ComposeError whose Step identifies the inner
+// Transformer, as documented for each combinator.
+
+// Return a Transformer that applies then to its argument if predicate returns
+// true for it, and otherwise applies otherwise, or returns its argument
+// unchanged if otherwise is nil.
+//
+// The Step of the inner ComposeError is 0 for then and 1 for otherwise.
+func If(predicate func(value any) bool, then Transformer, otherwise Transformer) Transformer {
+
+	s := &stage{
+		run: func(ctx context.Context, value any) (any, error) {
+
+			if predicate(value) {
+				return invoke(ctx, 0, then, value)
+			}
+
+			if otherwise == nil {
+				return value, nil
+			}
+
+			return invoke(ctx, 1, otherwise, value)
+		},
+		name: "If",
+		children: func() []Description {
+
+			children := []Description{describe("then", then)}
+
+			if otherwise != nil {
+				children = append(children, describe("otherwise", otherwise))
+			}
+
+			return children
+		},
+	}
+
+	return s.transformer()
+}
+
+// One case of a Switch().
+type Case struct {
+
+	// The key for which this case is selected.
+	Value any
+
+	// The Transformer to apply when this case is selected.
+	Then Transformer
+}
+
+// Return a Transformer that applies the Then of the first of the given cases
+// whose Value is equal to the key of its argument, or otherwise if none is.
+//
+// The key is the result of passing the argument to key or, if key is nil, the
+// argument itself. Keys are compared using ==, so comparing keys whose
+// dynamic types are the same but not comparable, e.g. slices, panics. If no
+// case matches and otherwise is nil, the Transformer fails with ErrNoCase.
+//
+// The Step of the inner ComposeError is the index of the selected case, or
+// len(cases) for otherwise.
+func Switch(key func(value any) any, otherwise Transformer, cases ...Case) Transformer {
+
+	s := &stage{
+		run: func(ctx context.Context, value any) (any, error) {
+
+			k := value
+
+			if key != nil {
+				k = key(value)
+			}
+
+			for i, c := range cases {
+				if c.Value == k {
+					return invoke(ctx, i, c.Then, value)
+				}
+			}
+
+			if otherwise == nil {
+				return nil, fmt.Errorf("%w for %v", ErrNoCase, k)
+			}
+
+			return invoke(ctx, len(cases), otherwise, value)
+		},
+		name: "Switch",
+		children: func() []Description {
+
+			children := []Description{}
+
+			for _, c := range cases {
+				children = append(children, describe(fmt.Sprintf("case %v", c.Value), c.Then))
+			}
+
+			if otherwise != nil {
+				children = append(children, describe("otherwise", otherwise))
+			}
+
+			return children
+		},
+	}
+
+	return s.transformer()
+}
+
+// Return a Transformer that applies body to its argument and, if body panics
+// or returns an error, returns the result of passing the same argument and the
+// ComposeError describing body's failure to catch instead.
+//
+// A failure of catch itself is not caught; the Step of the inner ComposeError
+// for it is 1.
+func Try(body Transformer, catch func(value any, err *ComposeError) any) Transformer {
+
+	s := &stage{
+		run: func(ctx context.Context, value any) (any, error) {
+
+			result, err := invoke(ctx, 0, body, value)
+
+			if err == nil {
+				return result, nil
+			}
+
+			handler := Transformer(func(value any) any {
+				return catch(value, err.(*ComposeError))
+			})
+
+			return invoke(ctx, 1, handler, value)
+		},
+		name: "Try",
+		children: func() []Description {
+
+			return []Description{
+				describe("body", body),
+				{Label: "catch", Name: "func(any, *ComposeError) any"},
+			}
+		},
+	}
+
+	return s.transformer()
+}
+
+// Return a Transformer that repeatedly applies body, starting with its
+// argument, until predicate returns true for the result, which it then
+// returns. Since predicate is checked before body is first applied, the
+// argument is returned unchanged if it already satisfies predicate.
+//
+// The Transformer fails with ErrIterationLimit if predicate is not satisfied
+// after limit applications of body, and stops between iterations when the
+// context passed by ComposeContext() is done.
+//
+// The Step of the inner ComposeError is the zero-based iteration in which body
+// failed.
+func Until(predicate func(value any) bool, body Transformer, limit int) Transformer {
+
+	s := &stage{
+		run: func(ctx context.Context, value any) (any, error) {
+
+			for iteration := 0; !predicate(value); iteration++ {
+
+				if iteration >= limit {
+					return nil, fmt.Errorf("%w: %d iterations", ErrIterationLimit, limit)
+				}
+
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+
+				result, err := invoke(ctx, iteration, body, value)
+
+				if err != nil {
+					return nil, err
+				}
+
+				value = result
+			}
+
+			return value, nil
+		},
+		name: fmt.Sprintf("Until (at most %d iterations)", limit),
+		children: func() []Description {
+			return []Description{describe("body", body)}
+		},
+	}
+
+	return s.transformer()
+}
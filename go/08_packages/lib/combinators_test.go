@@ -0,0 +1,212 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// Return the ComposeError for the outer step and the one nested inside it.
+func nested(t *testing.T, err error) (*ComposeError, *ComposeError) {
+
+	t.Helper()
+
+	outer, ok := err.(*ComposeError)
+
+	if !ok {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	inner, ok := outer.Err.(*ComposeError)
+
+	if !ok {
+		t.Fatalf("expected a nested *ComposeError, got %v of type %T", outer.Err, outer.Err)
+	}
+
+	return outer, inner
+}
+
+func TestIf(t *testing.T) {
+
+	big := func(value any) bool { return value.(int) > 10 }
+	sub := MakeTransformer(func(value int) int { return value - 10 })
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	for input, expected := range map[int]int{5: 6, 15: 6} {
+
+		result, err := Compose(input, If(big, sub, nil), add)
+
+		if err != nil || result != expected {
+			t.Errorf("%d: expected %d and no error, got %v and %v", input, expected, result, err)
+		}
+	}
+
+	result, err := Compose(5, If(big, sub, add))
+
+	if err != nil || result != 6 {
+		t.Errorf("expected 6 and no error, got %v and %v", result, err)
+	}
+
+	fail := MakeTransformer(func(value float64) float64 { return value })
+
+	_, err = Compose(5, add, If(big, sub, fail))
+	outer, inner := nested(t, err)
+
+	if outer.Step != 1 || inner.Step != 1 || !inner.IsRuntimeError() {
+		t.Errorf("expected a runtime error in the otherwise branch of step 1, got %v", err)
+	}
+}
+
+func TestSwitch(t *testing.T) {
+
+	double := MakeTransformer(func(value int) int { return value * 2 })
+	negate := MakeTransformer(func(value int) int { return -value })
+	zero := MakeTransformer(func(value int) int { return 0 })
+
+	parity := func(value any) any { return value.(int) % 2 }
+
+	transformer := Switch(parity, zero, Case{0, double}, Case{1, negate})
+
+	for input, expected := range map[int]int{2: 4, 3: -3} {
+
+		result, err := Compose(input, transformer)
+
+		if err != nil || result != expected {
+			t.Errorf("%d: expected %d and no error, got %v and %v", input, expected, result, err)
+		}
+	}
+
+	// A nil key function switches on the value itself.
+	exact := Switch(nil, zero, Case{7, double})
+
+	if result, _ := Compose(7, exact); result != 14 {
+		t.Errorf("expected 14, got %v", result)
+	}
+
+	if result, _ := Compose(8, exact); result != 0 {
+		t.Errorf("expected 0, got %v", result)
+	}
+
+	if _, err := Compose(8, Switch(nil, nil, Case{7, double})); !errors.Is(err, ErrNoCase) {
+		t.Errorf("expected ErrNoCase, got %v", err)
+	}
+
+	fail := MakeFallibleTransformer(func(value int) (int, error) { return 0, errors.New("failed") })
+
+	_, err := Compose(3, Switch(parity, nil, Case{0, double}, Case{1, fail}))
+	_, inner := nested(t, err)
+
+	if inner.Step != 1 {
+		t.Errorf("expected case 1 to have failed, got %v", err)
+	}
+}
+
+func TestTry(t *testing.T) {
+
+	div := MakeTransformer(func(value int) int { return 100 / value })
+
+	fallback := Try(div, func(value any, err *ComposeError) any {
+
+		if !err.IsRuntimeError() {
+			t.Errorf("expected a runtime error, got %v", err)
+		}
+
+		return -1
+	})
+
+	for input, expected := range map[int]int{4: 25, 0: -1} {
+
+		result, err := Compose(input, fallback)
+
+		if err != nil || result != expected {
+			t.Errorf("%d: expected %d and no error, got %v and %v", input, expected, result, err)
+		}
+	}
+
+	rethrow := Try(div, func(value any, err *ComposeError) any { panic(err) })
+
+	_, err := Compose(0, rethrow)
+	_, inner := nested(t, err)
+
+	if inner.Step != 1 {
+		t.Errorf("expected catch to have failed, got %v", err)
+	}
+}
+
+func TestUntil(t *testing.T) {
+
+	done := func(value any) bool { return value.(int) >= 100 }
+	double := MakeTransformer(func(value int) int { return value * 2 })
+
+	result, err := Compose(3, Until(done, double, 10))
+
+	if err != nil || result != 192 {
+		t.Errorf("expected 192 and no error, got %v and %v", result, err)
+	}
+
+	result, err = Compose(300, Until(done, double, 0))
+
+	if err != nil || result != 300 {
+		t.Errorf("expected 300 and no error, got %v and %v", result, err)
+	}
+
+	if _, err := Compose(3, Until(done, double, 3)); !errors.Is(err, ErrIterationLimit) {
+		t.Errorf("expected ErrIterationLimit, got %v", err)
+	}
+
+	count := 0
+
+	failing := MakeTransformer(func(value int) int {
+
+		count += 1
+
+		if count == 3 {
+			panic("third time unlucky")
+		}
+
+		return value * 2
+	})
+
+	_, err = Compose(3, Until(done, failing, 10))
+	_, inner := nested(t, err)
+
+	if inner.Step != 2 || inner.Recovered != "third time unlucky" {
+		t.Errorf("expected a panic in iteration 2, got %v", err)
+	}
+}
+
+func TestUntilCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stop := MakeTransformer(func(value int) int {
+		cancel()
+		return value + 1
+	})
+
+	_, err := ComposeContext(ctx, 0, Until(func(any) bool { return false }, stop, 10))
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCombinatorsNest(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	odd := func(value any) bool { return value.(int)%2 == 1 }
+
+	// Increment until odd, then until even again, but only for small values.
+	transformer := If(
+		func(value any) bool { return value.(int) < 10 },
+		Until(odd, Until(func(value any) bool { return !odd(value) }, add, 1), 5),
+		nil)
+
+	result, err := Compose(2, add, transformer)
+
+	if err != nil || result != 3 {
+		t.Errorf("expected 3 and no error, got %v and %v", result, err)
+	}
+}
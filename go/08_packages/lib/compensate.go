@@ -0,0 +1,96 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+)
+
+// Turn a function with side effects, together with a function that undoes
+// them, into a Transformer.
+//
+// When a later step of the same call to Compose(), ComposeContext() etc. fails,
+// undo is called with the value that forward returned, and the undo functions of
+// all the compensable steps that completed before the failure are called in the
+// reverse of the order in which those steps ran. This is the "saga" pattern for
+// keeping a sequence of individually irreversible operations all-or-nothing. It
+// is much like the way the "after" thunks of nested calls to dynamic-wind run
+// as the stack unwinds in ../../../scheme/continuations.scm, except that
+// compensation only runs on failure.
+//
+// The step that failed is not itself compensated, since it did not complete;
+// forward should therefore leave nothing behind when it returns an error or
+// panics. Failures of the undo functions do not stop the remaining ones from
+// running and are reported in the Compensations of the resulting ComposeError,
+// alongside the original failure.
+//
+// Compensation is not performed by ComposeStream(), where there is no single
+// pipeline run to unwind, nor by ComposeResumable() and Result.Resume(), since
+// the Result records the steps that completed as done, so that resuming skips
+// them. Likewise, when the Composer has a Checkpoint, only the steps that
+// completed since the last checkpoint was saved are compensated, since those
+// are the ones that continuing from the checkpoint runs again.
+func MakeCompensable[In, Out any](forward func(In) (Out, error), undo func(Out) error) Transformer {
+
+	s := &stage{
+		run: func(_ context.Context, a any) (any, error) {
+
+			result, err := forward(a.(In))
+
+			if err != nil {
+				return nil, err
+			}
+
+			return result, nil
+		},
+		in:  reflect.TypeFor[In](),
+		out: reflect.TypeFor[Out](),
+		undo: func(output any) error {
+			return undo(output.(Out))
+		},
+	}
+
+	return s.transformer()
+}
+
+// A completed step whose effects can be undone.
+type compensation struct {
+	step   int
+	undo   func(output any) error
+	output any
+}
+
+// Undo the given completed steps in reverse order, recording their failures in
+// err, which is returned.
+func compensate(err *ComposeError, completed []compensation) *ComposeError {
+
+	for i := len(completed) - 1; i >= 0; i-- {
+		if failure := completed[i].run(); failure != nil {
+			err.Compensations = append(err.Compensations, failure)
+		}
+	}
+
+	return err
+}
+
+// Run the compensating function, recovering from a panic.
+func (c compensation) run() (err error) {
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf(
+				"compensating Transformer %d: %w",
+				c.step,
+				recoveredError(recovered, debug.Stack()))
+		}
+	}()
+
+	if err := c.undo(c.output); err != nil {
+		return fmt.Errorf("compensating Transformer %d: %w", c.step, err)
+	}
+
+	return nil
+}
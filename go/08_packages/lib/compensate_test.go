@@ -0,0 +1,118 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Return a compensable Transformer that appends "+name" to log when it runs
+// and "-name" when it is compensated.
+func provision(name string, log *[]string) Transformer {
+
+	return MakeCompensable(
+		func(value int) (int, error) {
+			*log = append(*log, "+"+name)
+			return value + 1, nil
+		},
+		func(value int) error {
+			*log = append(*log, fmt.Sprintf("-%s(%d)", name, value))
+			return nil
+		})
+}
+
+func TestMakeCompensable(t *testing.T) {
+
+	log := []string{}
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	fail := MakeFallibleTransformer(func(value int) (int, error) {
+		return 0, errors.New("quota exceeded")
+	})
+
+	_, err := Compose(0, provision("a", &log), add, provision("b", &log), fail, provision("c", &log))
+
+	expected := "+a,+b,-b(3),-a(1)"
+
+	if strings.Join(log, ",") != expected {
+		t.Errorf("expected %s, got %v", expected, log)
+	}
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 3 || len(composeError.Compensations) != 0 {
+		t.Errorf("expected a failure in step 3 with no compensation failures, got %+v", composeError)
+	}
+}
+
+func TestMakeCompensableSuccess(t *testing.T) {
+
+	log := []string{}
+
+	result, err := Compose(0, provision("a", &log), provision("b", &log))
+
+	if err != nil || result != 2 {
+		t.Errorf("expected 2 and no error, got %v and %v", result, err)
+	}
+
+	if strings.Join(log, ",") != "+a,+b" {
+		t.Errorf("expected nothing to be compensated, got %v", log)
+	}
+}
+
+func TestMakeCompensableFailures(t *testing.T) {
+
+	log := []string{}
+	leaked := errors.New("leaked")
+
+	broken := MakeCompensable(
+		func(value int) (int, error) { return value, nil },
+		func(value int) error { return leaked })
+
+	panicky := MakeCompensable(
+		func(value int) (int, error) { return value, nil },
+		func(value int) error { panic("oops") })
+
+	sub := MakeTransformer(func(value float64) float64 { return value - 1.0 })
+
+	_, err := Compose(0, provision("a", &log), broken, panicky, sub)
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 3 || !composeError.IsRuntimeError() {
+		t.Errorf("expected the original failure in step 3, got %+v", composeError)
+	}
+
+	if len(composeError.Compensations) != 2 {
+		t.Fatalf("expected 2 compensation failures, got %v", composeError.Compensations)
+	}
+
+	if !strings.Contains(composeError.Compensations[0].Error(), "compensating Transformer 2: panic: oops") {
+		t.Errorf("expected the panic in step 2's compensation first, got %v", composeError.Compensations[0])
+	}
+
+	if !errors.Is(composeError.Compensations[1], leaked) {
+		t.Errorf("expected step 1's compensation error second, got %v", composeError.Compensations[1])
+	}
+
+	// A failing compensation does not prevent earlier ones from running.
+	if strings.Join(log, ",") != "+a,-a(1)" {
+		t.Errorf("expected a to be compensated, got %v", log)
+	}
+
+	if !strings.Contains(err.Error(), "2 compensations failed") {
+		t.Errorf("expected the message to report compensation failures, got %q", err.Error())
+	}
+}
@@ -9,7 +9,10 @@
 package lib
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"runtime/debug"
 )
 
 // Type of function that can be passed to Compose(any, ...Transfomer).
@@ -17,41 +20,195 @@ type Transformer func(value any) any
 
 // Turn a specific type of function into a Transformer. The returned Transformer
 // will panic if passed an argument of the wrong type.
+//
+// The returned Transformer records T as both the type it accepts and the type
+// it returns, so that Validate() can detect a mismatch before it is invoked.
 func MakeTransformer[T any](trans func(t T) T) Transformer {
+	return MakeMapper(trans)
+}
+
+// Turn a function that converts one type of value to another into a
+// Transformer, allowing pipelines whose steps change the type of the value
+// being passed along, e.g. to parse, enrich and then render it. The returned
+// Transformer will panic if passed an argument that is not of type A.
+//
+// The returned Transformer records A as the type it accepts and B as the type
+// it returns, so that Validate() can report exactly which types fail to line
+// up between adjacent steps.
+func MakeMapper[A, B any](trans func(a A) B) Transformer {
+
+	s := &stage{
+		run: func(_ context.Context, a any) (any, error) {
+			return trans(a.(A)), nil
+		},
+		in:    reflect.TypeFor[A](),
+		out:   reflect.TypeFor[B](),
+		typed: trans,
+	}
+
+	return s.transformer()
+}
+
+// Turn a specific type of function that can fail into a Transformer, in the
+// spirit of MultipleValues() in ../../01_basics/basics.go.
+//
+// When the function returns a non-nil error, Compose() and related functions
+// stop without invoking any subsequent Transformer and return a *ComposeError
+// whose Err is that error and whose Step is the position of the failing
+// Transformer. This allows expected failures, such as invalid input, to be
+// reported without resorting to panic(). Like MakeTransformer(), the returned
+// Transformer will panic if passed an argument of the wrong type.
+//
+// Transformers created by MakeTransformer() and MakeFallibleTransformer() can
+// be freely mixed in a single call to Compose().
+func MakeFallibleTransformer[T any](trans func(t T) (T, error)) Transformer {
+
+	s := &stage{
+		run: func(_ context.Context, a any) (any, error) {
+
+			result, err := trans(a.(T))
 
-	return func(a any) any {
-		return trans(a.(T))
+			if err != nil {
+				return nil, err
+			}
+
+			return result, nil
+		},
+		in:  reflect.TypeFor[T](),
+		out: reflect.TypeFor[T](),
 	}
+
+	return s.transformer()
 }
 
 // Return the result of applying each of the given Transformer functions to the
 // result of invoking the previous one, starting by passing the given initial
 // value to the first function.
 //
-// Returns the final result and nil, or nil and an error value if any of the
-// given Transformer functions cause a panic.
+// Returns the final result and nil, or nil and a *ComposeError if any of the
+// given Transformer functions cause a panic or, in the case of those created by
+// MakeFallibleTransformer(), return an error. No Transformer after the one that
+// failed is invoked.
 func Compose(value any, transformers ...Transformer) (any, error) {
+	return defaultComposer.Compose(value, transformers...)
+}
 
-	var recovered any = nil
+// Like Compose(any, ...Transformer), but for pipelines whose initial value and
+// result are both of type T.
+//
+// When every one of the given Transformers was created by MakeTransformer()
+// for the same T, they are run on a fast path that calls the underlying
+// func(T) T functions directly, without boxing the value into an any at each
+// step or deferring a recover() per step, and so, for up to 32 steps, without
+// allocating unless one of them panics. Otherwise, this is equivalent to calling Compose() and
+// asserting that its result is of type T. Either way, the result is the same as
+// Compose() would return, including the *ComposeError when a Transformer
+// panics, except that an error is also returned if the final result is not of
+// type T.
+//
+// ComposeOf() has no Options, so use a Composer where those are needed.
+func ComposeOf[T any](value T, transformers ...Transformer) (T, error) {
 
-	invoke := func(transformer Transformer) any {
-		defer func() { recovered = recover() }()
-		return transformer(value)
+	// A nil interface value must fail the type assertion made by the
+	// Transformer, as it does for Compose(), which calling the typed function
+	// directly would skip.
+	if reflect.TypeFor[T]().Kind() == reflect.Interface {
+		return composeOf(value, transformers)
 	}
 
+	// Enough for most pipelines without allocating.
+	var buffer [32]func(T) T
+	typed := buffer[:0]
+
 	for _, transformer := range transformers {
-		value = invoke(transformer)
-		if recovered != nil {
-			break
+
+		s := inspect(transformer)
+
+		if s == nil {
+			return composeOf(value, transformers)
+		}
+
+		f, ok := s.typed.(func(T) T)
+
+		if !ok {
+			return composeOf(value, transformers)
+		}
+
+		typed = append(typed, f)
+	}
+
+	return composeHomogeneous(value, typed)
+}
+
+// The slow path for ComposeOf().
+func composeOf[T any](value T, transformers []Transformer) (T, error) {
+
+	var zero T
+
+	result, err := Compose(value, transformers...)
+
+	if err != nil {
+		return zero, err
+	}
+
+	typed, ok := result.(T)
+
+	if !ok {
+		return zero, fmt.Errorf(
+			"ComposeOf() expected a result of type %v, got %v of type %T",
+			reflect.TypeFor[T](),
+			result,
+			result)
+	}
+
+	return typed, nil
+}
+
+// The fast path for ComposeOf(), given the functions from which each of its
+// Transformers was created by MakeTransformer().
+func composeHomogeneous[T any](value T, typed []func(T) T) (result T, err error) {
+
+	step := 0
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			var zero T
+			result, err = zero, newComposeError(step, value, recovered, debug.Stack())
 		}
+	}()
+
+	for step = range typed {
+		value = typed[step](value)
 	}
 
-	if recovered == nil {
-		return value, nil
+	return value, nil
+}
+
+// Invoke a single Transformer, converting a panic or error into a
+// *ComposeError that records the given step index.
+func invoke(ctx context.Context, step int, transformer Transformer, value any) (any, error) {
+	return invokeStage(ctx, step, inspect(transformer), transformer, value)
+}
+
+// Like invoke() but for a Transformer whose *stage is already known, as for
+// callStage().
+func invokeStage(ctx context.Context, step int, s *stage, transformer Transformer, value any) (result any, err error) {
+
+	defer func() {
+
+		// Note that as of Go 1.21, panic(nil) causes recover() to return a
+		// *runtime.PanicNilError rather than nil, so a nil result here reliably
+		// means that no panic occurred.
+		if recovered := recover(); recovered != nil {
+			err = newComposeError(step, value, recovered, debug.Stack())
+		}
+	}()
+
+	result, err = callStage(ctx, s, transformer, value)
+
+	if err != nil {
+		return nil, newStepError(step, value, err)
 	}
 
-	return nil,
-		fmt.Errorf(
-			"Compose() recovered from a panic in a Transformer: %v",
-			recovered)
+	return result, nil
 }
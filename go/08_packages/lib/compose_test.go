@@ -3,6 +3,11 @@
 package lib
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -58,3 +63,342 @@ func TestComposePanic(t *testing.T) {
 		t.Errorf("expected transformer1 to have been called twice, got %d", count)
 	}
 }
+
+func TestComposeFallible(t *testing.T) {
+
+	count := 0
+
+	add := MakeTransformer(func(value int) int {
+		count += 1
+		return value + 1
+	})
+
+	even := MakeFallibleTransformer(func(value int) (int, error) {
+
+		if value%2 == 1 {
+			return 0, fmt.Errorf("only even numbers are supported: %d", value)
+		}
+
+		return value, nil
+	})
+
+	result, err := Compose(0, add, add, even, add)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if result != 3 {
+		t.Errorf("expected 3, got %v", result)
+	}
+
+	count = 0
+
+	result, err = Compose(0, add, even, add)
+
+	if result != nil {
+		t.Errorf("expected result to be nil, got %v of type %T", result, result)
+	}
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 1 || composeError.Err == nil || composeError.Recovered != nil {
+		t.Errorf("expected an error rather than a panic in step 1, got %+v", composeError)
+	}
+
+	if !strings.Contains(err.Error(), "only even numbers are supported: 1") {
+		t.Errorf("expected the original message, got %q", err.Error())
+	}
+
+	if count != 1 {
+		t.Errorf("expected add to have been called once, got %d", count)
+	}
+}
+
+func TestComposeFallibleSentinel(t *testing.T) {
+
+	sentinel := errors.New("sentinel")
+
+	fail := MakeFallibleTransformer(func(value string) (string, error) { return "", sentinel })
+
+	_, err := Compose("", fail)
+
+	if !errors.Is(err, sentinel) {
+		t.Errorf("expected sentinel, got %v", err)
+	}
+}
+
+func TestComposeMapper(t *testing.T) {
+
+	parse := MakeMapper(func(value string) int {
+
+		n, err := strconv.Atoi(value)
+
+		if err != nil {
+			panic(err)
+		}
+
+		return n
+	})
+
+	half := MakeMapper(func(value int) float64 { return float64(value) / 2.0 })
+	render := MakeMapper(func(value float64) string { return fmt.Sprintf("%.1f", value) })
+
+	result, err := Compose("3", parse, half, render)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if result != "1.5" {
+		t.Errorf("expected \"1.5\", got %v", result)
+	}
+
+	_, err = Compose("three", parse, half, render)
+
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected strconv.ErrSyntax, got %v", err)
+	}
+}
+
+func TestComposeMapperMismatch(t *testing.T) {
+
+	parse := MakeMapper(func(value string) int { return len(value) })
+	render := MakeMapper(func(value float64) string { return fmt.Sprint(value) })
+
+	err := Validate("three", parse, render)
+
+	var typeError *TypeError
+
+	if !errors.As(err, &typeError) {
+		t.Fatalf("expected a *TypeError, got %v of type %T", err, err)
+	}
+
+	if typeError.Step != 1 ||
+		typeError.Want != reflect.TypeFor[float64]() ||
+		typeError.Got != reflect.TypeFor[int]() {
+		t.Errorf("expected step 1 to want float64 and get int, got %+v", typeError)
+	}
+
+	expected := "Transformer 1 accepts float64 but Transformer 0 returns int"
+
+	if err.Error() != expected {
+		t.Errorf("expected %q, got %q", expected, err.Error())
+	}
+
+	_, err = Compose("three", parse, render)
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) || composeError.Step != 1 || !composeError.IsRuntimeError() {
+		t.Errorf("expected a runtime error in step 1, got %v", err)
+	}
+}
+
+func TestComposeOf(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	sub := MakeTransformer(func(value int) int { return value - 1 })
+
+	result, err := ComposeOf(0, add, add, sub, add)
+
+	if err != nil || result != 2 {
+		t.Errorf("expected 2 and no error, got %v and %v", result, err)
+	}
+
+	// A plain Transformer forces the slow path, with the same result.
+	plain := Transformer(func(value any) any { return value.(int) + 1 })
+
+	result, err = ComposeOf(0, add, plain, sub, add)
+
+	if err != nil || result != 2 {
+		t.Errorf("expected 2 and no error, got %v and %v", result, err)
+	}
+
+	render := MakeMapper(func(value int) string { return strconv.Itoa(value) })
+
+	if _, err := ComposeOf(0, add, render); err == nil {
+		t.Errorf("expected an error for a result of the wrong type")
+	}
+}
+
+func TestComposeOfPanic(t *testing.T) {
+
+	count := 0
+
+	add := MakeTransformer(func(value int) int {
+		count += 1
+		return value + 1
+	})
+
+	sub := MakeTransformer(func(value float64) float64 {
+		count += 1
+		return value - 1.0
+	})
+
+	div := MakeTransformer(func(value int) int {
+		count += 1
+		return 100 / value
+	})
+
+	// Mixed types take the slow path, which behaves exactly like Compose().
+	result, err := ComposeOf(0, add, add, sub, add)
+
+	if err == nil || result != 0 || count != 2 {
+		t.Errorf("expected an error, 0 and 2 calls, got %v, %v and %d", err, result, count)
+	}
+
+	// A panic on the fast path is reported in the same way.
+	count = 0
+
+	result, err = ComposeOf(-2, add, add, div, add)
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 2 || composeError.Value != 0 || !composeError.IsRuntimeError() {
+		t.Errorf("expected a runtime error in step 2 with value 0, got %+v", composeError)
+	}
+
+	if result != 0 || count != 3 {
+		t.Errorf("expected 0 and 3 calls, got %v and %d", result, count)
+	}
+}
+
+func TestComposeOfNilInterface(t *testing.T) {
+
+	identity := MakeTransformer(func(value any) any { return value })
+	toNil := MakeTransformer(func(value any) any { return nil })
+	str := MakeTransformer(func(value fmt.Stringer) fmt.Stringer { return value })
+
+	// Asserting that nil is of an interface type fails, so a nil value passed
+	// to or returned by a step is reported by ComposeOf() as by Compose().
+	tests := []struct {
+		name  string
+		of    func() error
+		plain func() error
+	}{
+		{
+			"any",
+			func() error { _, err := ComposeOf[any](nil, identity); return err },
+			func() error { _, err := Compose(nil, identity); return err },
+		},
+		{
+			"any mid-chain",
+			func() error { _, err := ComposeOf[any](1, toNil, identity); return err },
+			func() error { _, err := Compose(1, toNil, identity); return err },
+		},
+		{
+			"fmt.Stringer",
+			func() error { _, err := ComposeOf[fmt.Stringer](nil, str); return err },
+			func() error { _, err := Compose(nil, str); return err },
+		},
+	}
+
+	for _, test := range tests {
+
+		var of, plain *ComposeError
+
+		if !errors.As(test.of(), &of) || !errors.As(test.plain(), &plain) {
+			t.Errorf("%s: expected both to fail with a *ComposeError, got %v and %v", test.name, test.of(), test.plain())
+			continue
+		}
+
+		if of.Step != plain.Step || of.Error() != plain.Error() {
+			t.Errorf("%s: expected the same error, got %v and %v", test.name, of, plain)
+		}
+	}
+}
+
+func TestComposeOfAllocations(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	transformers := []Transformer{add, add, add, add}
+
+	allocations := testing.AllocsPerRun(100, func() {
+		ComposeOf(1000, transformers...)
+	})
+
+	if allocations != 0 {
+		t.Errorf("expected no allocations, got %v", allocations)
+	}
+}
+
+// A pipeline of 20 homogeneous steps, as used by the benchmarks below. The
+// initial value is large enough that boxing it allocates.
+var benchmarkTransformers = func() []Transformer {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	transformers := make([]Transformer, 20)
+
+	for i := range transformers {
+		transformers[i] = add
+	}
+
+	return transformers
+}()
+
+// The same pipeline run by a plain loop that recovers from a panic in each
+// step, as Compose() originally did, against which to measure the cost of the
+// features it has gained since.
+func BenchmarkComposeBaseline(b *testing.B) {
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+
+		var value any = 1000
+		var recovered any
+
+		invoke := func(transformer Transformer) any {
+			defer func() { recovered = recover() }()
+			return transformer(value)
+		}
+
+		for _, transformer := range benchmarkTransformers {
+
+			value = invoke(transformer)
+
+			if recovered != nil {
+				b.Fatal(recovered)
+			}
+		}
+	}
+}
+
+func BenchmarkCompose(b *testing.B) {
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		Compose(1000, benchmarkTransformers...)
+	}
+}
+
+func BenchmarkComposeOf(b *testing.B) {
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ComposeOf(1000, benchmarkTransformers...)
+	}
+}
+
+func BenchmarkComposeOfSlowPath(b *testing.B) {
+
+	plain := Transformer(func(value any) any { return value })
+	transformers := append([]Transformer{plain}, benchmarkTransformers...)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		ComposeOf(1000, transformers...)
+	}
+}
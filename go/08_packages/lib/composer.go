@@ -0,0 +1,223 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"time"
+)
+
+// Applies Transformer functions in the manner of Compose(), with additional
+// behavior determined by the Options with which it was created.
+//
+// The zero value behaves exactly like Compose() and ComposeContext().
+type Composer struct {
+
+	// Whether to call Validate() before invoking any Transformer.
+	validate bool
+
+	// Capacity of the channels created by ComposeStream().
+	buffer int
+
+	// Notified before and after each step.
+	observers []Observer
+
+	// Where intermediate values are persisted, if anywhere.
+	checkpoint *checkpointer
+
+	// Maximum duration of each step, or zero for no limit.
+	timeout time.Duration
+}
+
+// Configures a Composer created by NewComposer().
+type Option func(*Composer)
+
+// The Composer used by Compose() and ComposeContext().
+var defaultComposer = &Composer{}
+
+// Return a Composer configured by the given Options.
+func NewComposer(options ...Option) *Composer {
+
+	c := &Composer{}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// Cause the Composer to call Validate() before invoking any Transformer,
+// returning the resulting *TypeError without invoking any of them if the chain
+// is not well-typed.
+func ValidateTypes() Option {
+
+	return func(c *Composer) {
+		c.validate = true
+	}
+}
+
+// Like Compose(any, ...Transformer) but with the behavior configured for c.
+func (c *Composer) Compose(value any, transformers ...Transformer) (any, error) {
+	return c.ComposeContext(context.Background(), value, transformers...)
+}
+
+// Like ComposeContext(context.Context, any, ...Transformer) but with the
+// behavior configured for c.
+func (c *Composer) ComposeContext(ctx context.Context, value any, transformers ...Transformer) (any, error) {
+
+	if c.validate {
+		if err := Validate(value, transformers...); err != nil {
+			return nil, err
+		}
+	}
+
+	result, _, err := c.compose(ctx, value, transformers, 0, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Apply transformers[start:] to value, returning the final value and
+// len(transformers) if all of them succeed. Otherwise, return the last value
+// successfully produced, i.e. the one that was passed to the step that failed,
+// the number of steps completed so far and the error.
+//
+// When c has a checkpoint store and start is zero, steps recorded as complete
+// by a checkpoint are skipped.
+//
+// Steps are compensated on failure only if they would be run again by
+// starting over: not at all when resumable is true, since the returned number
+// of completed steps tells the caller to skip them, and only those completed
+// since the last checkpoint was saved when c has a checkpoint store.
+func (c *Composer) compose(ctx context.Context, value any, transformers []Transformer, start int, resumable bool) (any, int, error) {
+
+	if c.checkpoint != nil {
+
+		if start == 0 {
+
+			restored, completed, err := c.checkpoint.restore(transformers)
+
+			if err != nil {
+				return value, 0, err
+			}
+
+			if completed > 0 {
+				value, start = restored, completed
+			}
+		}
+
+		if start == len(transformers) {
+			return value, start, c.checkpoint.clear()
+		}
+	}
+
+	// Completed steps that can be compensated, in the order they completed.
+	completed := []compensation{}
+
+	unwind := func(err *ComposeError) error {
+
+		if resumable {
+			return err
+		}
+
+		return compensate(err, completed)
+	}
+
+	for step := start; step < len(transformers); step++ {
+
+		transformer := transformers[step]
+
+		if err := ctx.Err(); err != nil {
+			return value, step, unwind(newStepError(step, value, err))
+		}
+
+		s := inspect(transformer)
+		result, err := c.invoke(ctx, step, s, transformer, value)
+
+		if err != nil {
+			return value, step, unwind(err.(*ComposeError))
+		}
+
+		if s != nil && s.undo != nil {
+			completed = append(completed, compensation{step: step, undo: s.undo, output: result})
+		}
+
+		if c.checkpoint != nil && step+1 < len(transformers) {
+
+			if err := c.checkpoint.save(step+1, result); err != nil {
+
+				if resumable {
+					return result, step + 1, newStepError(step+1, result, err)
+				}
+
+				// The last checkpoint saved, if any, is from before this step,
+				// so it is treated as having failed and compensated along with
+				// any others that completed since then.
+				return value, step, unwind(newStepError(step, value, err))
+			}
+
+			// Starting over resumes after this step, so it and those before it
+			// must not be undone.
+			completed = completed[:0]
+		}
+
+		value = result
+	}
+
+	if c.checkpoint != nil {
+		return value, len(transformers), c.checkpoint.clear()
+	}
+
+	return value, len(transformers), nil
+}
+
+// Invoke a single step, whose *stage is s, as for invokeStage(), notifying c's
+// observers, if any, and subject to c's timeout, if any.
+func (c *Composer) invoke(ctx context.Context, step int, s *stage, transformer Transformer, value any) (any, error) {
+
+	if c.timeout > 0 {
+		transformer = WithTimeout(transformer, c.timeout)
+		s = inspect(transformer)
+	}
+
+	if len(c.observers) == 0 {
+		return invokeStage(ctx, step, s, transformer, value)
+	}
+
+	event := StepEvent{Context: ctx, Step: step, Input: value}
+
+	for _, observer := range c.observers {
+		observer.BeforeStep(event)
+	}
+
+	start := time.Now()
+	result, err := invokeStage(ctx, step, s, transformer, value)
+	event.Duration = time.Since(start)
+	event.Output = result
+	event.Err = err
+
+	// The error, if any, was created by invoke() and so is a *ComposeError for
+	// this step.
+	var recovered any
+
+	if composeError, ok := err.(*ComposeError); ok {
+		recovered = composeError.Recovered
+	}
+
+	// Notify observers in the reverse order, so that they nest like deferred
+	// function calls.
+	for i := len(c.observers) - 1; i >= 0; i-- {
+
+		if recovered != nil {
+			c.observers[i].OnPanic(event, recovered)
+		} else {
+			c.observers[i].AfterStep(event)
+		}
+	}
+
+	return result, err
+}
@@ -0,0 +1,44 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestComposer(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	sub := MakeTransformer(func(value int) int { return value - 1 })
+
+	var zero Composer
+
+	for _, composer := range []*Composer{&zero, NewComposer()} {
+
+		result, err := composer.Compose(0, add, add, sub, add)
+
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if result != 2 {
+			t.Errorf("expected 2, got %v", result)
+		}
+	}
+}
+
+func TestComposerContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	_, err := NewComposer().ComposeContext(ctx, 0, add)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+)
+
+// Like Compose(any, ...Transformer) except that ctx is checked before each step
+// and is passed to any Transformer created by MakeContextTransformer().
+//
+// Returns the final result and nil, or nil and a *ComposeError if any of the
+// given Transformer functions cause a panic or return an error, or if ctx is
+// done before all of them have been invoked. In the latter case the
+// ComposeError's Step is the index of the first Transformer that was not
+// invoked and its Err is ctx.Err(), so errors.Is(err, context.Canceled) etc.
+// work as expected.
+func ComposeContext(ctx context.Context, value any, transformers ...Transformer) (any, error) {
+	return defaultComposer.ComposeContext(ctx, value, transformers...)
+}
+
+// Turn a context-aware function into a Transformer.
+//
+// When invoked by ComposeContext(), the function is passed that function's ctx
+// so that long-running stages can watch for cancellation themselves; when
+// invoked in any other way, it is passed context.Background(). A non-nil error
+// returned by the function stops the pipeline just as a panic would, but is
+// reported as the Err rather than the Recovered value of the resulting
+// ComposeError.
+func MakeContextTransformer(trans func(ctx context.Context, value any) (any, error)) Transformer {
+
+	s := &stage{run: trans}
+	return s.transformer()
+}
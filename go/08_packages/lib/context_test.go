@@ -0,0 +1,140 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestComposeContext(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	double := MakeContextTransformer(func(ctx context.Context, value any) (any, error) {
+		return value.(int) * 2, nil
+	})
+
+	result, err := ComposeContext(context.Background(), 1, add, double, add)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if result != 5 {
+		t.Errorf("expected 5, got %v", result)
+	}
+}
+
+func TestComposeContextCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	count := 0
+
+	add := MakeTransformer(func(value int) int {
+		count += 1
+		return value + 1
+	})
+
+	stop := MakeTransformer(func(value int) int {
+		cancel()
+		return value
+	})
+
+	result, err := ComposeContext(ctx, 0, add, stop, add, add)
+
+	if result != nil {
+		t.Errorf("expected result to be nil, got %v of type %T", result, result)
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 2 {
+		t.Errorf("expected step 2, got %d", composeError.Step)
+	}
+
+	if count != 1 {
+		t.Errorf("expected add to have been called once, got %d", count)
+	}
+}
+
+func TestComposeContextDeadline(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	wait := MakeContextTransformer(func(ctx context.Context, value any) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	_, err := ComposeContext(ctx, 0, wait)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 0 || composeError.Recovered != nil {
+		t.Errorf("expected step 0 without a panic, got %+v", composeError)
+	}
+}
+
+func TestMakeContextTransformerDirect(t *testing.T) {
+
+	sentinel := errors.New("sentinel")
+
+	fail := MakeContextTransformer(func(ctx context.Context, value any) (any, error) {
+		return nil, sentinel
+	})
+
+	// Invoked from within an ordinary Transformer, the error surfaces as a
+	// panic that Compose() still reports as the step's Err.
+	wrapper := Transformer(func(value any) any { return fail(value) })
+
+	_, err := Compose(0, wrapper)
+
+	if !errors.Is(err, sentinel) {
+		t.Fatalf("expected sentinel, got %v", err)
+	}
+
+	var composeError *ComposeError
+
+	if errors.As(err, &composeError) && composeError.Recovered != nil {
+		t.Errorf("expected Err rather than Recovered, got %+v", composeError)
+	}
+}
+
+func TestInspect(t *testing.T) {
+
+	plain := Transformer(func(value any) any { return value })
+
+	if inspect(plain) != nil {
+		t.Errorf("expected a plain Transformer not to be stage-backed")
+	}
+
+	staged := MakeContextTransformer(func(ctx context.Context, value any) (any, error) {
+		return value, nil
+	})
+
+	if inspect(staged) == nil {
+		t.Errorf("expected MakeContextTransformer() to return a stage-backed Transformer")
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright Kirk Rader 2024
+
+// Package dag runs Transformers arranged as a directed acyclic graph rather
+// than the linear chain of lib.Compose(), so that work which does not depend
+// on other work can be done concurrently, e.g. the diamond:
+//
+//	graph, err := dag.NewBuilder().
+//		Add("parse", parse).
+//		Add("price", price, "parse").
+//		Add("stock", stock, "parse").
+//		Join("quote", quote, "price", "stock").
+//		Build()
+//
+// in which price and stock both consume the result of parse, and may run at
+// the same time, and quote combines their results.
+//
+// Each node is run as a single step by lib.ComposeContext(), or by a
+// lib.Composer, so a node that panics or fails produces a *lib.ComposeError
+// just as a step of a chain does. The nodes downstream of one that fails are
+// not run.
+package dag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+// Returned, wrapped, by Build() when the nodes' dependencies form a cycle.
+var ErrCycle = errors.New("cycle")
+
+// Combine the results of the nodes on which a node added by Builder.Join()
+// depends, keyed by their names.
+type Join func(inputs map[string]any) (any, error)
+
+// A node of a Graph.
+type node struct {
+	name        string
+	transformer lib.Transformer
+	deps        []string
+
+	// Whether the node was added by Builder.Join(), and so is passed a map of
+	// its dependencies' results even if it has only one.
+	join bool
+}
+
+// Collects the nodes of a Graph. Create using NewBuilder().
+//
+// Errors in the nodes' definitions are reported by Build() rather than as each
+// node is added, so that calls can be chained.
+type Builder struct {
+	nodes  []*node
+	byName map[string]*node
+	errs   []error
+}
+
+// Return an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{byName: map[string]*node{}}
+}
+
+// Add a node with the given name that passes the result of the node named by
+// dep to transformer, or, with no dep, passes the value given to Graph.Run().
+// Use Join() for a node that depends on more than one other.
+func (b *Builder) Add(name string, transformer lib.Transformer, dep ...string) *Builder {
+
+	if transformer == nil {
+		b.errs = append(b.errs, fmt.Errorf("node %q has a nil Transformer", name))
+		return b
+	}
+
+	if len(dep) > 1 {
+		b.errs = append(b.errs, fmt.Errorf("node %q depends on %d nodes; use Join()", name, len(dep)))
+		return b
+	}
+
+	return b.add(&node{name: name, transformer: transformer, deps: slices.Clone(dep)})
+}
+
+// Add a node with the given name that passes the results of the nodes named by
+// deps, of which there must be at least one, to join.
+func (b *Builder) Join(name string, join Join, deps ...string) *Builder {
+
+	if join == nil {
+		b.errs = append(b.errs, fmt.Errorf("node %q has a nil Join", name))
+		return b
+	}
+
+	if len(deps) == 0 {
+		b.errs = append(b.errs, fmt.Errorf("join %q depends on no nodes", name))
+		return b
+	}
+
+	transformer := lib.MakeContextTransformer(func(_ context.Context, value any) (any, error) {
+		return join(value.(map[string]any))
+	})
+
+	return b.add(&node{
+		name:        name,
+		transformer: lib.Named("Join", transformer),
+		deps:        slices.Clone(deps),
+		join:        true,
+	})
+}
+
+// Add n, recording an error if its name or dependencies are repeated.
+func (b *Builder) add(n *node) *Builder {
+
+	if _, ok := b.byName[n.name]; ok {
+		b.errs = append(b.errs, fmt.Errorf("duplicate node %q", n.name))
+		return b
+	}
+
+	for i, dep := range n.deps {
+		if slices.Contains(n.deps[:i], dep) {
+			b.errs = append(b.errs, fmt.Errorf("node %q depends on %q more than once", n.name, dep))
+			return b
+		}
+	}
+
+	b.nodes = append(b.nodes, n)
+	b.byName[n.name] = n
+	return b
+}
+
+// Return the Graph of the nodes added so far, or an error describing every
+// node that was defined incorrectly, depends on a node that does not exist or
+// is part of a cycle.
+func (b *Builder) Build() (*Graph, error) {
+
+	errs := slices.Clone(b.errs)
+
+	for _, n := range b.nodes {
+		for _, dep := range n.deps {
+			if _, ok := b.byName[dep]; !ok {
+				errs = append(errs, fmt.Errorf("node %q depends on unknown node %q", n.name, dep))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	g := &Graph{byName: maps.Clone(b.byName), dependents: map[string][]*node{}}
+
+	// Kahn's algorithm: repeatedly take the nodes whose dependencies have all
+	// been taken, in the order they were added.
+	waiting := map[string]int{}
+	ready := []*node{}
+
+	for _, n := range b.nodes {
+
+		waiting[n.name] = len(n.deps)
+
+		if len(n.deps) == 0 {
+			ready = append(ready, n)
+		}
+
+		for _, dep := range n.deps {
+			g.dependents[dep] = append(g.dependents[dep], n)
+		}
+	}
+
+	for len(ready) > 0 {
+
+		n := ready[0]
+		ready = ready[1:]
+		g.nodes = append(g.nodes, n)
+
+		for _, dependent := range g.dependents[n.name] {
+
+			waiting[dependent.name] -= 1
+
+			if waiting[dependent.name] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(g.nodes) < len(b.nodes) {
+		return nil, b.cycle(waiting)
+	}
+
+	return g, nil
+}
+
+// Return an error naming the nodes of one of the cycles that prevented the
+// nodes still waiting for dependencies from being sorted.
+//
+// Every such node depends on at least one other such node, so following those
+// dependencies from any of them must eventually revisit one.
+func (b *Builder) cycle(waiting map[string]int) error {
+
+	var n *node
+
+	for _, candidate := range b.nodes {
+		if waiting[candidate.name] > 0 {
+			n = candidate
+			break
+		}
+	}
+
+	path := []string{}
+	visited := map[string]int{}
+
+	for {
+
+		if i, ok := visited[n.name]; ok {
+			path = append(path[i:], n.name)
+			break
+		}
+
+		visited[n.name] = len(path)
+		path = append(path, n.name)
+
+		for _, dep := range n.deps {
+			if waiting[dep] > 0 {
+				n = b.byName[dep]
+				break
+			}
+		}
+	}
+
+	// The path follows dependencies, so reverse it to follow the flow of data.
+	slices.Reverse(path)
+	return fmt.Errorf("%w: %s", ErrCycle, strings.Join(path, " → "))
+}
+
+// Nodes whose Transformers are run concurrently as their dependencies
+// complete. Create using Builder.Build().
+type Graph struct {
+
+	// Maximum number of nodes run at once, or zero or less for
+	// runtime.GOMAXPROCS(0). Must not be changed while the Graph is running.
+	Parallelism int
+
+	// Runs each node, e.g. to observe it or to retry failures, or nil for
+	// lib.ComposeContext(). Since nodes run concurrently, it must not be
+	// configured with lib.Checkpoint(). Must not be changed while the Graph is
+	// running.
+	Composer *lib.Composer
+
+	// In an order in which each node follows those on which it depends.
+	nodes []*node
+
+	// Each node, by name.
+	byName map[string]*node
+
+	// The nodes that depend on each node, by name.
+	dependents map[string][]*node
+}
+
+// Return the names of g's nodes in an order in which each follows those on
+// which it depends.
+func (g *Graph) Names() []string {
+
+	names := make([]string, len(g.nodes))
+
+	for i, n := range g.nodes {
+		names[i] = n.name
+	}
+
+	return names
+}
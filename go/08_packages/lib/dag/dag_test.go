@@ -0,0 +1,120 @@
+// Copyright Kirk Rader 2024
+
+package dag
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+var increment = lib.MakeTransformer(func(value int) int { return value + 1 })
+
+// Return the sum of the ints in inputs.
+func sum(inputs map[string]any) (any, error) {
+
+	total := 0
+
+	for _, value := range inputs {
+		total += value.(int)
+	}
+
+	return total, nil
+}
+
+func TestBuild(t *testing.T) {
+
+	g, err := NewBuilder().
+		Join("d", sum, "b", "c").
+		Add("b", increment, "a").
+		Add("c", increment, "a").
+		Add("a", increment).
+		Build()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := g.Names()
+
+	if !slices.Equal(names, []string{"a", "b", "c", "d"}) {
+		t.Errorf("expected [a b c d], got %v", names)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+
+	_, err := NewBuilder().
+		Add("a", increment).
+		Add("a", increment).
+		Add("b", nil).
+		Add("c", increment, "a", "a").
+		Join("d", sum).
+		Join("e", nil, "a").
+		Join("f", sum, "a", "a").
+		Add("g", increment, "missing").
+		Build()
+
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	for _, expected := range []string{
+		`duplicate node "a"`,
+		`node "b" has a nil Transformer`,
+		`node "c" depends on 2 nodes; use Join()`,
+		`join "d" depends on no nodes`,
+		`node "e" has a nil Join`,
+		`node "f" depends on "a" more than once`,
+		`node "g" depends on unknown node "missing"`,
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in %q", expected, err)
+		}
+	}
+}
+
+func TestBuildCycle(t *testing.T) {
+
+	_, err := NewBuilder().
+		Add("a", increment).
+		Add("b", increment, "a").
+		Join("c", sum, "b", "e").
+		Add("d", increment, "c").
+		Add("e", increment, "d").
+		Add("f", increment, "e").
+		Build()
+
+	if !errors.Is(err, ErrCycle) {
+		t.Fatalf("expected ErrCycle, got %v", err)
+	}
+
+	if err.Error() != "cycle: c → d → e → c" {
+		t.Errorf("expected \"cycle: c → d → e → c\", got %q", err)
+	}
+
+	_, err = NewBuilder().Add("a", increment, "a").Build()
+
+	if err == nil || err.Error() != "cycle: a → a" {
+		t.Errorf("expected \"cycle: a → a\", got %v", err)
+	}
+}
+
+func TestBuildIsolated(t *testing.T) {
+
+	b := NewBuilder().Add("a", increment)
+	g, err := b.Build()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Add("b", increment, "a")
+
+	if names := g.Names(); !slices.Equal(names, []string{"a"}) {
+		t.Errorf("expected nodes added after Build() not to affect the Graph, got %v", names)
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright Kirk Rader 2024
+
+package dag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+// Returned, wrapped, as the error of each node that was not run because a node
+// on which it depends, directly or indirectly, failed.
+var ErrSkipped = errors.New("skipped")
+
+// The outcome of running one node.
+type Result struct {
+
+	// The value the node produced, or nil if it failed or was skipped.
+	Value any
+
+	// Nil if the node succeeded. Otherwise, a *lib.ComposeError, or, for a
+	// node that was skipped, an error wrapping both ErrSkipped and the error
+	// of the node whose failure caused it to be skipped.
+	Err error
+}
+
+// The Result of each node of a Graph, by name.
+type Results map[string]Result
+
+// Return the errors of the nodes that failed, in order of name, joined by
+// errors.Join(), or nil if none did. Nodes that were skipped are not included,
+// since their errors repeat those of the nodes that failed.
+func (r Results) Err() error {
+
+	names := []string{}
+
+	for name, result := range r {
+		if result.Err != nil && !errors.Is(result.Err, ErrSkipped) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+	errs := make([]error, len(names))
+
+	for i, name := range names {
+		errs[i] = fmt.Errorf("node %q: %w", name, r[name].Err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// A node's Result, sent by the goroutine that ran it.
+type outcome struct {
+	node   *node
+	result Result
+}
+
+// Run each node once all of those on which it depends have succeeded, passing
+// value to those that depend on none, and return the Result of every node.
+//
+// Nodes whose dependencies have succeeded are started in the order returned by
+// Names(), at most g.Parallelism at a time. When a node fails, those that
+// depend on it, directly or indirectly, are skipped, but the others continue
+// to run. Values are passed to each node that depends on the node that
+// produced them, possibly concurrently, so Transformers must not modify their
+// arguments.
+//
+// When ctx is done, nodes that are running are passed a context that is done
+// and nodes that have not started fail, as do the steps of ComposeContext().
+func (g *Graph) Run(ctx context.Context, value any) Results {
+
+	parallelism := g.Parallelism
+
+	if parallelism < 1 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+
+	results := make(Results, len(g.nodes))
+
+	// The number of each node's dependencies that have not yet succeeded.
+	waiting := map[string]int{}
+	ready := []*node{}
+
+	for _, n := range g.nodes {
+
+		waiting[n.name] = len(n.deps)
+
+		if len(n.deps) == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	finished := make(chan outcome)
+	running := 0
+
+	for len(ready) > 0 || running > 0 {
+
+		for len(ready) > 0 && running < parallelism {
+
+			n := ready[0]
+			ready = ready[1:]
+			input := g.input(n, value, results)
+			running += 1
+
+			go func() {
+				result, err := g.compose(ctx, input, n.transformer)
+				finished <- outcome{node: n, result: Result{Value: result, Err: err}}
+			}()
+		}
+
+		o := <-finished
+		running -= 1
+		results[o.node.name] = o.result
+
+		if o.result.Err != nil {
+			g.skip(o.node, o.node, results)
+			continue
+		}
+
+		for _, dependent := range g.dependents[o.node.name] {
+
+			waiting[dependent.name] -= 1
+
+			if waiting[dependent.name] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	return results
+}
+
+// Return a Transformer that runs g, passing its argument to Run(), and returns
+// the value produced by the node with the given name or, if that node failed
+// or was skipped, its error, so that a Graph can be a step of a chain.
+func (g *Graph) Transformer(output string) lib.Transformer {
+
+	transformer := lib.MakeContextTransformer(func(ctx context.Context, value any) (any, error) {
+
+		if _, ok := g.byName[output]; !ok {
+			return nil, fmt.Errorf("no node %q", output)
+		}
+
+		result := g.Run(ctx, value)[output]
+		return result.Value, result.Err
+	})
+
+	return lib.Named("DAG", transformer)
+}
+
+// Return the value to pass to n, given the results of the nodes on which it
+// depends and the value passed to Run().
+func (g *Graph) input(n *node, value any, results Results) any {
+
+	switch {
+
+	case len(n.deps) == 0:
+		return value
+
+	case !n.join:
+		return results[n.deps[0]].Value
+
+	default:
+		inputs := make(map[string]any, len(n.deps))
+
+		for _, dep := range n.deps {
+			inputs[dep] = results[dep].Value
+		}
+
+		return inputs
+	}
+}
+
+// Run a single node's Transformer.
+func (g *Graph) compose(ctx context.Context, value any, transformer lib.Transformer) (any, error) {
+
+	if g.Composer == nil {
+		return lib.ComposeContext(ctx, value, transformer)
+	}
+
+	return g.Composer.ComposeContext(ctx, value, transformer)
+}
+
+// Record every node downstream of n, which has either failed or been skipped,
+// as skipped because of the failure of the given node.
+func (g *Graph) skip(failed *node, n *node, results Results) {
+
+	for _, dependent := range g.dependents[n.name] {
+
+		if _, ok := results[dependent.name]; ok {
+			continue
+		}
+
+		results[dependent.name] = Result{
+			Err: fmt.Errorf("%w because node %q failed: %w", ErrSkipped, failed.name, results[failed.name].Err),
+		}
+
+		g.skip(failed, dependent, results)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright Kirk Rader 2024
+
+package dag
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+// Return the diamond a → b, c → d, where a increments, b and c are as given
+// and d sums.
+func diamond(t *testing.T, b, c lib.Transformer) *Graph {
+
+	g, err := NewBuilder().
+		Add("a", increment).
+		Add("b", b, "a").
+		Add("c", c, "a").
+		Join("d", sum, "b", "c").
+		Build()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return g
+}
+
+func TestRun(t *testing.T) {
+
+	double := lib.MakeTransformer(func(value int) int { return value * 2 })
+	triple := lib.MakeTransformer(func(value int) int { return value * 3 })
+	results := diamond(t, double, triple).Run(context.Background(), 1)
+
+	if err := results.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]int{"a": 2, "b": 4, "c": 6, "d": 10} {
+		if value := results[name].Value; value != expected {
+			t.Errorf("expected %d for %s, got %v", expected, name, value)
+		}
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+
+	// b and c each wait for the other to start, which can only succeed if they
+	// run at the same time.
+	var started sync.WaitGroup
+	started.Add(2)
+
+	meet := lib.MakeFallibleTransformer(func(value int) (int, error) {
+
+		started.Done()
+		done := make(chan struct{})
+
+		go func() {
+			started.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return value, nil
+		case <-time.After(5 * time.Second):
+			return 0, errors.New("nodes did not run concurrently")
+		}
+	})
+
+	g := diamond(t, meet, meet)
+	g.Parallelism = 2
+
+	if err := g.Run(context.Background(), 1).Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunParallelism(t *testing.T) {
+
+	var running, most atomic.Int32
+
+	track := lib.MakeTransformer(func(value int) int {
+
+		n := running.Add(1)
+
+		for m := most.Load(); n > m && !most.CompareAndSwap(m, n); m = most.Load() {
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return value
+	})
+
+	b := NewBuilder()
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		b.Add(name, track)
+	}
+
+	g, err := b.Build()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, parallelism := range []int{1, 3} {
+
+		most.Store(0)
+		g.Parallelism = parallelism
+
+		if err := g.Run(context.Background(), 1).Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := most.Load(); n > int32(parallelism) {
+			t.Errorf("expected at most %d nodes at once, got %d", parallelism, n)
+		}
+	}
+}
+
+func TestRunFailure(t *testing.T) {
+
+	explode := lib.MakeTransformer(func(value int) int { panic("boom") })
+	double := lib.MakeTransformer(func(value int) int { return value * 2 })
+
+	g, err := NewBuilder().
+		Add("a", increment).
+		Add("b", explode, "a").
+		Add("c", double, "a").
+		Join("d", sum, "b", "c").
+		Add("e", increment, "d").
+		Build()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := g.Run(context.Background(), 1)
+
+	var composeErr *lib.ComposeError
+
+	if !errors.As(results["b"].Err, &composeErr) || composeErr.Recovered != "boom" {
+		t.Errorf("expected a *lib.ComposeError recovered from a panic for b, got %v", results["b"].Err)
+	}
+
+	if results["c"].Err != nil || results["c"].Value != 4 {
+		t.Errorf("expected c to succeed with 4, got %v and %v", results["c"].Value, results["c"].Err)
+	}
+
+	for _, name := range []string{"d", "e"} {
+
+		err := results[name].Err
+
+		if !errors.Is(err, ErrSkipped) || !errors.As(err, &composeErr) {
+			t.Errorf("expected %s to be skipped because of b's panic, got %v", name, err)
+		}
+
+		if !strings.Contains(err.Error(), `node "b" failed`) {
+			t.Errorf("expected %s's error to name b, got %v", name, err)
+		}
+	}
+
+	err = results.Err()
+
+	if !strings.HasPrefix(err.Error(), `node "b": `) || strings.Contains(err.Error(), `node "d"`) {
+		t.Errorf("expected only b's error, got %v", err)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	double := lib.MakeTransformer(func(value int) int { return value * 2 })
+	results := diamond(t, double, double).Run(ctx, 1)
+
+	if !errors.Is(results["a"].Err, context.Canceled) {
+		t.Errorf("expected a to fail with context.Canceled, got %v", results["a"].Err)
+	}
+
+	if !errors.Is(results["d"].Err, ErrSkipped) {
+		t.Errorf("expected d to be skipped, got %v", results["d"].Err)
+	}
+}
+
+func TestRunComposer(t *testing.T) {
+
+	g, err := NewBuilder().Add("a", increment).Build()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.Composer = lib.NewComposer(lib.ValidateTypes())
+
+	if result := g.Run(context.Background(), 1)["a"]; result.Err != nil || result.Value != 2 {
+		t.Errorf("expected 2 and no error, got %v and %v", result.Value, result.Err)
+	}
+
+	if result := g.Run(context.Background(), "one")["a"]; result.Err == nil {
+		t.Errorf("expected the Composer to reject a string")
+	}
+}
+
+func TestTransformer(t *testing.T) {
+
+	double := lib.MakeTransformer(func(value int) int { return value * 2 })
+	g := diamond(t, double, double)
+
+	result, err := lib.Compose(1, g.Transformer("d"), increment)
+
+	if err != nil || result != 9 {
+		t.Errorf("expected 9 and no error, got %v and %v", result, err)
+	}
+
+	if _, err := lib.Compose(1, g.Transformer("z")); err == nil || !strings.Contains(err.Error(), `no node "z"`) {
+		t.Errorf("expected an error naming z, got %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// The structure of a Transformer, or of a chain of them, as returned by
+// Describe().
+type Description struct {
+
+	// What the Transformer is: the name given to Named(), the name of the
+	// combinator that created it, e.g. "If", its type, e.g. "func(int) string"
+	// for one created by MakeMapper(), or the name of the function for one that
+	// is not stage-backed.
+	Name string
+
+	// The role of the Transformer within its parent, e.g. "then" for If(), or
+	// its index for a step of a chain or Pipeline. Empty for the root and for
+	// Transformers wrapped by, e.g., WithRetry().
+	Label string
+
+	// The types the Transformer accepts and returns, where known, or nil.
+	In, Out reflect.Type
+
+	// The Transformers invoked by this one, e.g. the branches of If().
+	Children []Description
+}
+
+// Return a Transformer that behaves exactly like the given one, but that is
+// described by Describe() using the given name.
+func Named(name string, transformer Transformer) Transformer {
+
+	if inner := inspect(transformer); inner != nil {
+		named := *inner
+		named.name = name
+		return named.transformer()
+	}
+
+	s := &stage{
+		run: func(ctx context.Context, value any) (any, error) {
+			return call(ctx, transformer, value)
+		},
+		name: name,
+	}
+
+	return s.transformer()
+}
+
+// Return the Description of a chain of Transformers, as they would be applied
+// by Compose(). The root is named "Compose" and its children are the given
+// Transformers, labeled with their indices.
+func Describe(transformers ...Transformer) Description {
+	return Description{Name: "Compose", Children: describeSteps(transformers...)}
+}
+
+// Return the Descriptions of the given Transformers, labeled with their
+// indices.
+func describeSteps(transformers ...Transformer) []Description {
+
+	children := make([]Description, len(transformers))
+
+	for i, transformer := range transformers {
+		children[i] = describe(strconv.Itoa(i), transformer)
+	}
+
+	return children
+}
+
+// Return the Description of a single Transformer with the given label.
+func describe(label string, transformer Transformer) Description {
+
+	d := Description{Label: label}
+	s := inspect(transformer)
+
+	if s == nil {
+
+		d.Name = "Transformer"
+
+		if transformer == nil {
+			d.Name = "nil"
+		} else if f := runtime.FuncForPC(reflect.ValueOf(transformer).Pointer()); f != nil {
+			d.Name = f.Name()
+		}
+
+		return d
+	}
+
+	d.Name, d.In, d.Out = s.name, s.in, s.out
+
+	if d.Name == "" {
+		d.Name = typeName(s.in, s.out)
+	}
+
+	if s.children != nil {
+		d.Children = s.children()
+	}
+
+	return d
+}
+
+// Return the name of a function type with the given parameter and result
+// types, or "Transformer" if either is unknown.
+func typeName(in, out reflect.Type) string {
+
+	if in == nil || out == nil {
+		return "Transformer"
+	}
+
+	return fmt.Sprintf("func(%v) %v", in, out)
+}
+
+// Return d as indented text, one line per Transformer, e.g.
+//
+//	Compose
+//	  0: func(int) int
+//	  1: If
+//	    then: double (func(int) int)
+//	    otherwise: func(int) int
+func (d Description) String() string {
+
+	builder := &strings.Builder{}
+	d.text(builder, 0)
+	return builder.String()
+}
+
+// Write d to builder at the given depth of indentation.
+func (d Description) text(builder *strings.Builder, depth int) {
+
+	builder.WriteString(strings.Repeat("  ", depth))
+
+	if d.Label != "" {
+		builder.WriteString(d.Label)
+		builder.WriteString(": ")
+	}
+
+	builder.WriteString(d.Name)
+
+	if name := typeName(d.In, d.Out); d.In != nil && d.Out != nil && name != d.Name {
+		fmt.Fprintf(builder, " (%s)", name)
+	}
+
+	builder.WriteString("\n")
+
+	for _, child := range d.Children {
+		child.text(builder, depth+1)
+	}
+}
+
+// Return d in the Graphviz DOT language, as a tree of boxes with an edge from
+// each Transformer to each of its children, labeled with the child's Label,
+// ready to be rendered by, e.g., `dot -Tsvg`.
+func (d Description) DOT() string {
+
+	builder := &strings.Builder{}
+	builder.WriteString("digraph {\n\tnode [shape=box];\n")
+	d.dot(builder, new(int))
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+// Write the node for d and the subtree below it to builder, numbering nodes
+// using *next, and return d's node's number.
+func (d Description) dot(builder *strings.Builder, next *int) int {
+
+	id := *next
+	*next += 1
+
+	label := d.Name
+
+	if name := typeName(d.In, d.Out); d.In != nil && d.Out != nil && name != d.Name {
+		label += "\n" + name
+	}
+
+	fmt.Fprintf(builder, "\tn%d [label=%s];\n", id, strconv.Quote(label))
+
+	for _, child := range d.Children {
+
+		childID := child.dot(builder, next)
+
+		if child.Label == "" {
+			fmt.Fprintf(builder, "\tn%d -> n%d;\n", id, childID)
+		} else {
+			fmt.Fprintf(builder, "\tn%d -> n%d [label=%s];\n", id, childID, strconv.Quote(child.Label))
+		}
+	}
+
+	return id
+}
@@ -0,0 +1,125 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDescribe(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	double := Named("double", MakeTransformer(func(value int) int { return value * 2 }))
+	format := MakeMapper(strconv.Itoa)
+	big := func(value any) bool { return value.(int) > 10 }
+
+	description := Describe(
+		add,
+		If(big, double, nil),
+		WithRetry(format, RetryPolicy{MaxAttempts: 3}),
+		Switch(nil, nil, Case{"1", Memoize(add, MemoOptions{}).Transformer()}))
+
+	expected := `Compose
+  0: func(int) int
+  1: If
+    then: double (func(int) int)
+  2: WithRetry (at most 3 attempts) (func(int) string)
+    func(int) string
+  3: Switch
+    case 1: Memoize (func(int) int)
+      func(int) int
+`
+
+	if text := description.String(); text != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, text)
+	}
+
+	// Naming does not change behavior.
+	if result, err := Compose(3, add, double); err != nil || result != 8 {
+		t.Errorf("expected 8 and no error, got %v and %v", result, err)
+	}
+}
+
+// A Transformer that is not stage-backed.
+func echo(value any) any {
+	return value
+}
+
+func TestDescribeNested(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	p := Map(Then(NewPipeline[string](), strconv.Atoi), func(value int) float64 { return float64(value) })
+
+	description := Describe(
+		Named("wrapped", echo),
+		Until(func(any) bool { return true }, WithTimeout(add, time.Second), 5),
+		Try(p.Transformer(), func(value any, err *ComposeError) any { return 0.0 }),
+		Dispatch(Handle(func(value int) any { return value }), HandleNil(func() any { return 0 })),
+		echo)
+
+	expected := `Compose
+  0: wrapped
+  1: Until (at most 5 iterations)
+    body: WithTimeout (1s) (func(int) int)
+      func(int) int
+  2: Try
+    body: Pipeline (func(string) float64)
+      0: func(string) int
+      1: func(int) float64
+    catch: func(any, *ComposeError) any
+  3: Dispatch
+    int: func(int) any
+    nil: func() any
+  4: parasaurolophus/tutorial/08_packages/lib.echo
+`
+
+	if text := description.String(); text != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, text)
+	}
+}
+
+func TestDescribeDOT(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	dot := Describe(add, If(func(any) bool { return true }, Named(`say "hi"`, add), nil)).DOT()
+
+	for _, line := range []string{
+		"digraph {",
+		`n0 [label="Compose"];`,
+		`n1 [label="func(int) int"];`,
+		`n0 -> n1 [label="0"];`,
+		`n3 [label="say \"hi\"\nfunc(int) int"];`,
+		`n2 -> n3 [label="then"];`,
+		`n0 -> n2 [label="1"];`,
+	} {
+		if !strings.Contains(dot, line) {
+			t.Errorf("expected %q in:\n%s", line, dot)
+		}
+	}
+}
+
+func TestRegistryNames(t *testing.T) {
+
+	registry := NewRegistry()
+	registry.Register("double", MakeTransformer(func(value int) int { return value * 2 }))
+
+	RegisterParameterized(registry, "add", func(params struct{ N int }) (Transformer, error) {
+		return MakeTransformer(func(value int) int { return value + params.N }), nil
+	})
+
+	transformers, err := registry.Load(strings.NewReader(`{"stages": [{"name": "double"}, {"name": "add", "params": {"N": 3}}]}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Compose\n  0: double (func(int) int)\n  1: add{\"N\": 3} (func(int) int)\n"
+
+	if text := Describe(transformers...).String(); text != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, text)
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Returned, wrapped, by a Transformer created by Dispatch() when none of its
+// handlers accepts the type of value it is passed.
+var ErrNoHandler = errors.New("no handler")
+
+// Returned, wrapped, by a Transformer created by Dispatch() when more than one
+// of its handlers for interface types accepts the type of value it is passed
+// and none of them is more specific than all of the others.
+var ErrAmbiguous = errors.New("ambiguous handlers")
+
+// Handles one type of value passed to a Transformer created by Dispatch().
+type Handler struct {
+
+	// The type handled, or nil for the nil and default handlers.
+	typ reflect.Type
+
+	// Whether this is the handler for nil.
+	isNil bool
+
+	// Apply the handler to a value.
+	handle func(value any) any
+}
+
+// Return a Handler for values of type T, which may be an interface type.
+func Handle[T any](handle func(value T) any) Handler {
+
+	return Handler{
+		typ:    reflect.TypeFor[T](),
+		handle: func(value any) any { return handle(value.(T)) },
+	}
+}
+
+// Return the Handler for nil.
+func HandleNil(handle func() any) Handler {
+
+	return Handler{
+		isNil:  true,
+		handle: func(any) any { return handle() },
+	}
+}
+
+// Return the Handler for values not handled by any other Handler.
+func HandleDefault(handle func(value any) any) Handler {
+	return Handler{handle: handle}
+}
+
+// A table of Handlers, indexed for Dispatch().
+type dispatcher struct {
+	concrete   map[reflect.Type]Handler
+	interfaces []Handler
+	nilHandler *Handler
+	fallback   *Handler
+
+	// The Handler chosen for each dynamic type seen so far, or the error
+	// explaining why there is none.
+	resolved sync.Map
+}
+
+// Result of resolving a dynamic type, as cached by a dispatcher.
+type resolution struct {
+	handler Handler
+	err     error
+}
+
+// Return a Transformer that applies whichever of the given Handlers is the most
+// specific for the dynamic type of its argument, generalizing the type switch
+// in fn() in ../../07_any/any.go.
+//
+// Handlers are chosen as follows:
+//
+//   - nil is handled by the HandleNil() Handler, if any, otherwise by the
+//     HandleDefault() Handler, if any.
+//   - A Handler for the argument's exact dynamic type is chosen if there is
+//     one.
+//   - Otherwise, of the Handlers for interface types the argument implements,
+//     the one for the interface that implements all of the others is chosen.
+//     If there is no such interface, e.g. when the argument implements both
+//     fmt.Stringer and error, the Transformer fails with ErrAmbiguous.
+//   - Otherwise, the HandleDefault() Handler is chosen, if any.
+//   - Otherwise, the Transformer fails with ErrNoHandler.
+//
+// Dispatch() panics if more than one Handler is given for the same type, or if
+// more than one nil or default Handler is given, since that is a programming
+// error rather than something that depends on the values being transformed.
+func Dispatch(handlers ...Handler) Transformer {
+
+	d := &dispatcher{concrete: map[reflect.Type]Handler{}}
+
+	for _, handler := range handlers {
+		d.add(handler)
+	}
+
+	s := &stage{
+		run: func(_ context.Context, value any) (any, error) {
+
+			handler, err := d.resolve(value)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return handler.handle(value), nil
+		},
+		name:     "Dispatch",
+		children: func() []Description { return describeHandlers(handlers) },
+	}
+
+	return s.transformer()
+}
+
+// Add a Handler to d.
+func (d *dispatcher) add(handler Handler) {
+
+	switch {
+
+	case handler.isNil:
+		if d.nilHandler != nil {
+			panic("Dispatch(): more than one nil Handler")
+		}
+		d.nilHandler = &handler
+
+	case handler.typ == nil:
+		if d.fallback != nil {
+			panic("Dispatch(): more than one default Handler")
+		}
+		d.fallback = &handler
+
+	case handler.typ.Kind() == reflect.Interface:
+		for _, h := range d.interfaces {
+			if h.typ == handler.typ {
+				panic(fmt.Sprintf("Dispatch(): more than one Handler for %v", handler.typ))
+			}
+		}
+		d.interfaces = append(d.interfaces, handler)
+
+	default:
+		if _, ok := d.concrete[handler.typ]; ok {
+			panic(fmt.Sprintf("Dispatch(): more than one Handler for %v", handler.typ))
+		}
+		d.concrete[handler.typ] = handler
+	}
+}
+
+// Return the Handler for the given value.
+func (d *dispatcher) resolve(value any) (Handler, error) {
+
+	if value == nil {
+
+		if d.nilHandler != nil {
+			return *d.nilHandler, nil
+		}
+
+		if d.fallback != nil {
+			return *d.fallback, nil
+		}
+
+		return Handler{}, fmt.Errorf("%w for nil", ErrNoHandler)
+	}
+
+	typ := reflect.TypeOf(value)
+
+	if cached, ok := d.resolved.Load(typ); ok {
+		r := cached.(resolution)
+		return r.handler, r.err
+	}
+
+	handler, err := d.resolveType(typ)
+	d.resolved.Store(typ, resolution{handler: handler, err: err})
+	return handler, err
+}
+
+// Return the Handler for the given non-nil dynamic type.
+func (d *dispatcher) resolveType(typ reflect.Type) (Handler, error) {
+
+	if handler, ok := d.concrete[typ]; ok {
+		return handler, nil
+	}
+
+	candidates := []Handler{}
+
+	for _, handler := range d.interfaces {
+		if typ.Implements(handler.typ) {
+			candidates = append(candidates, handler)
+		}
+	}
+
+	// Keep only the candidates that no other candidate is more specific than.
+	specific := []Handler{}
+
+	for _, candidate := range candidates {
+
+		dominated := false
+
+		for _, other := range candidates {
+			if other.typ != candidate.typ && other.typ.Implements(candidate.typ) {
+				dominated = true
+				break
+			}
+		}
+
+		if !dominated {
+			specific = append(specific, candidate)
+		}
+	}
+
+	switch len(specific) {
+
+	case 0:
+		if d.fallback != nil {
+			return *d.fallback, nil
+		}
+		return Handler{}, fmt.Errorf("%w for %v", ErrNoHandler, typ)
+
+	case 1:
+		return specific[0], nil
+
+	default:
+		names := make([]string, len(specific))
+
+		for i, handler := range specific {
+			names[i] = handler.typ.String()
+		}
+
+		return Handler{}, fmt.Errorf(
+			"%w for %v: %s",
+			ErrAmbiguous,
+			typ,
+			strings.Join(names, ", "))
+	}
+}
+
+// Return the Descriptions of the given Handlers, labeled with the types they
+// handle.
+func describeHandlers(handlers []Handler) []Description {
+
+	children := make([]Description, len(handlers))
+
+	for i, handler := range handlers {
+
+		switch {
+
+		case handler.isNil:
+			children[i] = Description{Label: "nil", Name: "func() any"}
+
+		case handler.typ == nil:
+			children[i] = Description{Label: "default", Name: "func(any) any"}
+
+		default:
+			children[i] = Description{
+				Label: handler.typ.String(),
+				Name:  fmt.Sprintf("func(%v) any", handler.typ),
+			}
+		}
+	}
+
+	return children
+}
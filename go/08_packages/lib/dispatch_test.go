@@ -0,0 +1,103 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Implements both fmt.Stringer and error.
+type both struct{}
+
+func (both) String() string { return "both" }
+
+func (both) Error() string { return "both" }
+
+// Implements fmt.Stringer and a more specific interface that embeds it.
+type named struct{}
+
+func (named) String() string { return "named" }
+
+func (named) Name() string { return "name" }
+
+type namedStringer interface {
+	fmt.Stringer
+	Name() string
+}
+
+func TestDispatch(t *testing.T) {
+
+	transformer := Dispatch(
+		Handle(func(value int) any { return value + 1 }),
+		Handle(func(value string) any { return "string " + value }),
+		Handle(func(value fmt.Stringer) any { return "stringer " + value.String() }),
+		Handle(func(value namedStringer) any { return "named " + value.Name() }),
+		HandleNil(func() any { return "nil" }),
+		HandleDefault(func(value any) any { return fmt.Sprintf("default %T", value) }))
+
+	for _, test := range []struct {
+		input    any
+		expected any
+	}{
+		{41, 42},
+		{"s", "string s"},
+		{time.Second, "stringer 1s"},
+		{named{}, "named name"},
+		{nil, "nil"},
+		{1.5, "default float64"},
+	} {
+
+		result, err := Compose(test.input, transformer)
+
+		if err != nil || result != test.expected {
+			t.Errorf("%v: expected %v and no error, got %v and %v", test.input, test.expected, result, err)
+		}
+	}
+}
+
+func TestDispatchFailures(t *testing.T) {
+
+	transformer := Dispatch(
+		Handle(func(value fmt.Stringer) any { return value.String() }),
+		Handle(func(value error) any { return value.Error() }))
+
+	if _, err := Compose(both{}, transformer); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("expected ErrAmbiguous, got %v", err)
+	}
+
+	if _, err := Compose(42, transformer); !errors.Is(err, ErrNoHandler) {
+		t.Errorf("expected ErrNoHandler, got %v", err)
+	}
+
+	// Cached resolutions report the same errors.
+	if _, err := Compose(42, transformer); !errors.Is(err, ErrNoHandler) {
+		t.Errorf("expected ErrNoHandler, got %v", err)
+	}
+
+	if _, err := Compose(nil, transformer); !errors.Is(err, ErrNoHandler) {
+		t.Errorf("expected ErrNoHandler for nil, got %v", err)
+	}
+
+	// The default Handler handles nil when there is no nil Handler.
+	fallback := Dispatch(HandleDefault(func(value any) any { return "default" }))
+
+	if result, err := Compose(nil, fallback); err != nil || result != "default" {
+		t.Errorf("expected \"default\" and no error, got %v and %v", result, err)
+	}
+}
+
+func TestDispatchDuplicate(t *testing.T) {
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Dispatch() to panic")
+		}
+	}()
+
+	Dispatch(
+		Handle(func(value int) any { return value }),
+		Handle(func(value int) any { return value }))
+}
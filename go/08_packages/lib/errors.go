@@ -0,0 +1,273 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Error returned by Compose(any, ...Transformer) and related functions when one
+// of the Transformer functions panics or otherwise fails.
+//
+// Unlike the opaque string produced by fmt.Errorf(), a ComposeError preserves
+// enough information to tell which step of a pipeline failed, what it was
+// given and where the panic originated.
+type ComposeError struct {
+
+	// Zero-based index of the Transformer that failed.
+	Step int
+
+	// The value that was passed to the failing Transformer.
+	Value any
+
+	// The dynamic type of Value, or nil if Value was nil.
+	Type reflect.Type
+
+	// The value that was passed to panic(), or nil if the step failed without
+	// panicking.
+	Recovered any
+
+	// The error that caused the step to fail without panicking, e.g. one
+	// returned by a context-aware Transformer or the context's own error when
+	// it was cancelled before the step could run; nil if the step panicked.
+	Err error
+
+	// Stack trace of the goroutine at the point at which the panic was
+	// recovered, as returned by runtime/debug.Stack().
+	Stack []byte
+
+	// Number of times the step was attempted before giving up, if it was
+	// wrapped by WithRetry(); otherwise zero.
+	Attempts int
+
+	// Failures of the compensating functions of previously completed steps,
+	// created by MakeCompensable(), that were run because this step failed;
+	// nil if all of them succeeded or there were none.
+	Compensations []error
+}
+
+// Create a ComposeError for a panic in the given step.
+//
+// A stage-backed Transformer invoked directly reports its errors by panicking
+// with a *stageFailure, which is unpacked so that it is reported the same way as
+// when it is invoked by ComposeContext().
+func newComposeError(step int, value any, recovered any, stack []byte) *ComposeError {
+	return newStepError(step, value, recoveredError(recovered, stack))
+}
+
+// Create a ComposeError for a step that failed without panicking.
+//
+// Wrappers such as WithRetry() report the outcome of the Transformer they wrap
+// as an error, so err is unpacked to recover any panic or attempt count they
+// carry.
+func newStepError(step int, value any, err error) *ComposeError {
+
+	composeError := &ComposeError{
+		Step:  step,
+		Value: value,
+		Type:  reflect.TypeOf(value),
+	}
+
+	if r, ok := err.(*retried); ok {
+		composeError.Attempts = r.attempts
+		err = r.err
+	}
+
+	if p, ok := err.(*panicked); ok {
+		composeError.Recovered = p.recovered
+		composeError.Stack = p.stack
+		return composeError
+	}
+
+	composeError.Err = err
+	return composeError
+}
+
+// Carries a panic recovered by a wrapper Transformer, such as one created by
+// WithRetry(), to the point at which a ComposeError is created for it, so that
+// it is reported as a panic rather than as an ordinary error.
+type panicked struct {
+	recovered any
+	stack     []byte
+}
+
+// Implement the error interface.
+func (p *panicked) Error() string {
+	return fmt.Sprintf("panic: %v", p.recovered)
+}
+
+// Support errors.Is() and errors.As() for panics whose value is an error.
+func (p *panicked) Unwrap() error {
+
+	if err, ok := p.recovered.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// Carries the number of attempts made by a Transformer created by WithRetry()
+// to the point at which a ComposeError is created for its final failure.
+type retried struct {
+	attempts int
+	err      error
+}
+
+// Implement the error interface.
+func (r *retried) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", r.err, r.attempts)
+}
+
+// Support errors.Is() and errors.As().
+func (r *retried) Unwrap() error {
+	return r.err
+}
+
+// Implement the error interface.
+//
+// Note that the stack trace is deliberately omitted from the message since it
+// is typically far too long to be useful in a single log line. Use the Stack
+// field directly where it is wanted.
+//
+// When the step that failed was itself a pipeline, e.g. one created by Chain()
+// or a combinator such as If(), the message reports the path to the innermost
+// step that failed, e.g. "step 2 → step 1 → step 0", along with that step's
+// value and cause, rather than repeating them at every level.
+func (e *ComposeError) Error() string {
+
+	if e.nested() != nil {
+		return e.pathError()
+	}
+
+	attempts := ""
+
+	if e.Attempts > 1 {
+		attempts = fmt.Sprintf(" after %d attempts", e.Attempts)
+	}
+
+	if e.Err != nil {
+		return fmt.Sprintf(
+			"Compose() stopped at Transformer %d (%v of type %v)%s: %v%s",
+			e.Step,
+			e.Value,
+			e.Type,
+			attempts,
+			e.Err,
+			compensationFailures(e.Compensations))
+	}
+
+	return fmt.Sprintf(
+		"Compose() recovered from a panic in Transformer %d (%v of type %v)%s: %v%s",
+		e.Step,
+		e.Value,
+		e.Type,
+		attempts,
+		e.Recovered,
+		compensationFailures(e.Compensations))
+}
+
+// Return the message for a ComposeError with nested ComposeErrors.
+func (e *ComposeError) pathError() string {
+
+	steps := []string{}
+	compensations := []error{}
+	innermost := e
+
+	for current := e; current != nil; current = current.nested() {
+
+		step := fmt.Sprintf("step %d", current.Step)
+
+		if current.Attempts > 1 {
+			step += fmt.Sprintf(" (after %d attempts)", current.Attempts)
+		}
+
+		steps = append(steps, step)
+		compensations = append(compensations, current.Compensations...)
+		innermost = current
+	}
+
+	path := strings.Join(steps, " → ")
+
+	if innermost.Err != nil {
+		return fmt.Sprintf(
+			"Compose() stopped at %s (%v of type %v): %v%s",
+			path,
+			innermost.Value,
+			innermost.Type,
+			innermost.Err,
+			compensationFailures(compensations))
+	}
+
+	return fmt.Sprintf(
+		"Compose() recovered from a panic at %s (%v of type %v): %v%s",
+		path,
+		innermost.Value,
+		innermost.Type,
+		innermost.Recovered,
+		compensationFailures(compensations))
+}
+
+// Return the suffix of an error message reporting the given failures of
+// compensating functions, if any.
+func compensationFailures(compensations []error) string {
+
+	if len(compensations) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		" (and %d compensations failed: %v)",
+		len(compensations),
+		errors.Join(compensations...))
+}
+
+// Return the ComposeError for the failed step of the pipeline that failed as
+// e's step, or nil if e's step was not itself a pipeline.
+func (e *ComposeError) nested() *ComposeError {
+
+	inner, _ := e.Err.(*ComposeError)
+	return inner
+}
+
+// Return the index of the step that failed at each level of nesting, starting
+// with e.Step. For example, if step 2 of a pipeline is a Chain() whose step 1
+// is another Chain() whose step 0 panicked, the path is [2 1 0].
+func (e *ComposeError) Path() []int {
+
+	path := []int{}
+
+	for current := e; current != nil; current = current.nested() {
+		path = append(path, current.Step)
+	}
+
+	return path
+}
+
+// Support errors.Is() and errors.As() by returning Err or, if the step
+// panicked, the recovered value when it is itself an error, e.g. when a
+// Transformer calls panic(err) or the runtime panics with a runtime.Error.
+func (e *ComposeError) Unwrap() error {
+
+	if e.Err != nil {
+		return e.Err
+	}
+
+	if err, ok := e.Recovered.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
+// Return true if and only if the panic was raised by the Go runtime itself,
+// e.g. due to a failed type assertion, nil pointer dereference or index out of
+// range, as opposed to a deliberate call to panic() by a Transformer.
+func (e *ComposeError) IsRuntimeError() bool {
+
+	_, ok := e.Recovered.(runtime.Error)
+	return ok
+}
@@ -0,0 +1,96 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComposeErrorStep(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	sub := MakeTransformer(func(value float64) float64 { return value - 1.0 })
+
+	_, err := Compose(0, add, add, sub, add)
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 2 {
+		t.Errorf("expected step 2, got %d", composeError.Step)
+	}
+
+	if composeError.Value != 2 {
+		t.Errorf("expected value 2, got %v", composeError.Value)
+	}
+
+	if composeError.Type != reflect.TypeOf(0) {
+		t.Errorf("expected type int, got %v", composeError.Type)
+	}
+
+	if !composeError.IsRuntimeError() {
+		t.Errorf("expected a failed type assertion to be a runtime error")
+	}
+
+	if len(composeError.Stack) == 0 {
+		t.Errorf("expected a stack trace")
+	}
+
+	if !strings.Contains(composeError.Error(), "Transformer 2") {
+		t.Errorf("expected message to name the step, got %q", composeError.Error())
+	}
+}
+
+func TestComposeErrorUnwrap(t *testing.T) {
+
+	sentinel := errors.New("sentinel")
+
+	fail := Transformer(func(value any) any { panic(sentinel) })
+
+	_, err := Compose(0, fail)
+
+	if !errors.Is(err, sentinel) {
+		t.Errorf("expected errors.Is() to find the recovered error, got %v", err)
+	}
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.IsRuntimeError() {
+		t.Errorf("expected a deliberate panic not to be a runtime error")
+	}
+}
+
+func TestComposeErrorNonError(t *testing.T) {
+
+	fail := Transformer(func(value any) any { panic("deliberate") })
+
+	_, err := Compose(nil, fail)
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Recovered != "deliberate" {
+		t.Errorf("expected \"deliberate\", got %v", composeError.Recovered)
+	}
+
+	if composeError.Type != nil {
+		t.Errorf("expected nil type for nil value, got %v", composeError.Type)
+	}
+
+	if errors.Unwrap(err) != nil {
+		t.Errorf("expected nothing to unwrap, got %v", errors.Unwrap(err))
+	}
+}
@@ -0,0 +1,372 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Returned, wrapped, by a Process's Transformer when the subprocess exits, or
+// closes its stdout, before writing a result.
+var ErrProcessExited = errors.New("process exited")
+
+// Returned, wrapped, by a Process's Transformer when the subprocess writes a
+// line that is not valid JSON.
+var ErrMalformedOutput = errors.New("malformed output")
+
+// Returned, wrapped, by a Process's Transformer after Close() has been called.
+var ErrProcessClosed = errors.New("process closed")
+
+// How long Close() waits for a subprocess to exit after closing its stdin
+// before killing it.
+const closeGrace = time.Second
+
+// A long-lived subprocess that transforms values written to its stdin as JSON,
+// one per line, into values read from its stdout in the same way, e.g. a
+// Python script of the form:
+//
+//	for line in sys.stdin:
+//	    print(json.dumps(transform(json.loads(line))), flush=True)
+//
+// Create using NewProcess() and use as a step of a pipeline by way of
+// Transformer(). The subprocess is started when the Transformer is first
+// invoked and is sent one value at a time, so invocations from concurrent
+// pipelines are serialized.
+//
+// When the subprocess exits, crashes, writes malformed output or takes longer
+// than Timeout, the invocation fails and the subprocess is killed, if
+// necessary, and restarted by the next invocation. Combine with WithRetry() to
+// retry such failures.
+type Process struct {
+
+	// Maximum time to wait for each result, or zero for no limit. Must not be
+	// changed after the Transformer is first invoked.
+	Timeout time.Duration
+
+	// Where the subprocess's stderr is written, or nil to discard it, as for
+	// exec.Cmd. Must not be changed after the Transformer is first invoked.
+	Stderr io.Writer
+
+	// The command to run and its arguments.
+	name string
+	args []string
+
+	// Guards the fields below and serializes invocations.
+	mutex sync.Mutex
+
+	// The running subprocess, or nil if none is running.
+	child *child
+
+	// Whether Close() has been called.
+	closed bool
+}
+
+// A single run of a Process's subprocess.
+type child struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	// Each line the subprocess writes to stdout; closed when it closes stdout.
+	lines chan []byte
+
+	// Closed by stop() so that the goroutine reading stdout can give up.
+	done chan struct{}
+
+	// Closed once the subprocess has exited, after which err is its status.
+	exited chan struct{}
+	err    error
+}
+
+// Return a Process that runs the given command with the given arguments.
+func NewProcess(name string, args ...string) *Process {
+	return &Process{name: name, args: args}
+}
+
+// Return a Transformer that runs p.
+//
+// The Transformer writes its argument to the subprocess as a line of JSON and
+// returns the value of the next line of JSON it writes, decoded as by
+// json.Unmarshal() into an any, so that numbers become float64, objects
+// map[string]any and so on.
+//
+// The Transformer fails with an error wrapping:
+//
+//   - ErrProcessExited, including the exit status, if the subprocess exits
+//     before writing a result
+//   - ErrMalformedOutput if the result is not valid JSON
+//   - ErrTimeout if Timeout elapses before the argument has been written and
+//     the result read, e.g. because the subprocess has stopped reading
+//
+// or with the context's error if the context passed by ComposeContext() is
+// done first, in all of which cases the subprocess is restarted by the next
+// invocation. It also fails, leaving the subprocess running, if its argument
+// cannot be encoded as JSON.
+func (p *Process) Transformer() Transformer {
+
+	s := &stage{
+		run:  p.run,
+		name: strings.Join(append([]string{"Exec", p.name}, p.args...), " "),
+	}
+
+	return s.transformer()
+}
+
+// Return a Transformer that runs the given command as described for
+// Process.Transformer().
+//
+// The subprocess runs until the program exits. Use NewProcess() instead to set
+// a timeout or to stop the subprocess when it is no longer needed.
+func ExecTransformer(name string, args ...string) Transformer {
+	return NewProcess(name, args...).Transformer()
+}
+
+// Stop the subprocess, if it is running, and cause subsequent invocations of
+// p's Transformer to fail with ErrProcessClosed.
+//
+// The subprocess's stdin is closed, which a well-behaved subprocess treats as
+// a request to exit, and it is killed if it has not exited within a second.
+func (p *Process) Close() error {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+	c := p.child
+	p.child = nil
+
+	if c == nil {
+		return nil
+	}
+
+	c.stdin.Close()
+
+	select {
+	case <-c.exited:
+		close(c.done)
+		return c.err
+	case <-time.After(closeGrace):
+		c.stop()
+		return nil
+	}
+}
+
+// Implement the stage returned by Transformer().
+func (p *Process) run(ctx context.Context, value any) (any, error) {
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return nil, ErrProcessClosed
+	}
+
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.child == nil {
+		if p.child, err = p.start(); err != nil {
+			p.child = nil
+			return nil, err
+		}
+	}
+
+	c := p.child
+
+	var timeout <-chan time.Time
+
+	if p.Timeout > 0 {
+		timer := time.NewTimer(p.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	// Writing blocks once the pipe's buffer is full, e.g. if the subprocess is
+	// hung and has stopped reading, so it is done in a goroutine that can be
+	// abandoned. Killing the subprocess then causes the write to fail, ending
+	// the goroutine.
+	written := make(chan error, 1)
+
+	go func() {
+		_, err := c.stdin.Write(append(data, '\n'))
+		written <- err
+	}()
+
+	select {
+
+	case err := <-written:
+		if err != nil {
+			return nil, p.exited(c)
+		}
+
+	case <-timeout:
+		return nil, p.abandon(ctx)
+
+	case <-ctx.Done():
+		return nil, p.abandon(ctx)
+	}
+
+	select {
+
+	case line, ok := <-c.lines:
+		if !ok {
+			return nil, p.exited(c)
+		}
+
+		var result any
+
+		if err := json.Unmarshal(line, &result); err != nil {
+			p.restart()
+			return nil, fmt.Errorf("%w from %s: %q", ErrMalformedOutput, p.name, bytes.TrimSpace(line))
+		}
+
+		return result, nil
+
+	case <-timeout:
+		return nil, p.abandon(ctx)
+
+	case <-ctx.Done():
+		return nil, p.abandon(ctx)
+	}
+}
+
+// Start a new run of the subprocess.
+func (p *Process) start() (*child, error) {
+
+	cmd := exec.Command(p.name, p.args...)
+	cmd.Stderr = p.Stderr
+
+	stdin, err := cmd.StdinPipe()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Using an *os.File for stdout means that exec.Cmd.Wait() neither copies
+	// from it nor closes it, so every line written before the subprocess exits
+	// can still be read after it has.
+	stdout, writer, err := os.Pipe()
+
+	if err != nil {
+		return nil, err
+	}
+
+	cmd.Stdout = writer
+
+	if err := cmd.Start(); err != nil {
+		stdout.Close()
+		writer.Close()
+		return nil, err
+	}
+
+	// Only the subprocess should hold the write end, so that reading reaches
+	// EOF when it exits.
+	writer.Close()
+
+	c := &child{
+		cmd:    cmd,
+		stdin:  stdin,
+		lines:  make(chan []byte),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+
+	go c.read(stdout)
+
+	go func() {
+		c.err = cmd.Wait()
+		close(c.exited)
+	}()
+
+	return c, nil
+}
+
+// Goroutine that sends each line read from stdout on c.lines.
+func (c *child) read(stdout *os.File) {
+
+	defer stdout.Close()
+
+	reader := bufio.NewReader(stdout)
+
+	for {
+
+		line, err := reader.ReadBytes('\n')
+
+		if len(line) > 0 {
+			select {
+			case c.lines <- line:
+			case <-c.done:
+				return
+			}
+		}
+
+		if err != nil {
+			close(c.lines)
+			return
+		}
+	}
+}
+
+// Kill the subprocess, if it is still running, and wait for it to exit.
+func (c *child) stop() {
+
+	close(c.done)
+	c.cmd.Process.Kill()
+	<-c.exited
+}
+
+// Discard the current run of the subprocess, so that the next invocation
+// starts a new one.
+func (p *Process) restart() {
+
+	if p.child != nil {
+		p.child.stop()
+		p.child = nil
+	}
+}
+
+// Return the error for an invocation abandoned because Timeout elapsed or ctx
+// is done, discarding the subprocess, which may be part way through reading the
+// value or writing the result.
+func (p *Process) abandon(ctx context.Context) error {
+
+	p.restart()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w after %v", ErrTimeout, p.Timeout)
+}
+
+// Return the error for a subprocess that stopped accepting values or
+// producing results, waiting briefly for it to exit so that the error can
+// include its exit status.
+func (p *Process) exited(c *child) error {
+
+	select {
+	case <-c.exited:
+	case <-time.After(closeGrace):
+	}
+
+	p.restart()
+
+	if c.err == nil {
+		return fmt.Errorf("%w: %s exited without a result", ErrProcessExited, p.name)
+	}
+
+	return fmt.Errorf("%w: %s: %v", ErrProcessExited, p.name, c.err)
+}
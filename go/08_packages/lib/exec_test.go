@@ -0,0 +1,206 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Not a real test: the subprocess run by helperProcess(), which doubles each
+// number it reads and responds to the strings below as described, or, if
+// LIB_HELPER_DEAF is set, never reads anything.
+func TestHelperProcess(t *testing.T) {
+
+	if os.Getenv("LIB_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	defer os.Exit(0)
+
+	if os.Getenv("LIB_HELPER_DEAF") == "1" {
+		time.Sleep(time.Minute)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for scanner.Scan() {
+
+		var value any
+
+		if err := json.Unmarshal(scanner.Bytes(), &value); err != nil {
+			os.Exit(2)
+		}
+
+		switch value {
+
+		case "pid":
+			fmt.Println(os.Getpid())
+
+		case "crash":
+			os.Exit(3)
+
+		case "garbage":
+			fmt.Println("not json")
+
+		case "hang":
+			time.Sleep(time.Minute)
+
+		default:
+			fmt.Println(value.(float64) * 2)
+		}
+	}
+}
+
+// Return a Process running TestHelperProcess().
+func helperProcess(t *testing.T) *Process {
+
+	t.Setenv("LIB_HELPER_PROCESS", "1")
+
+	// Under -race, the subprocess would otherwise linger for a second after
+	// its stdin is closed, causing Close() to kill it.
+	t.Setenv("GORACE", "atexit_sleep_ms=0")
+
+	p := NewProcess(os.Args[0], "-test.run=^TestHelperProcess$")
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestExecTransformer(t *testing.T) {
+
+	p := helperProcess(t)
+	double := p.Transformer()
+	add := MakeTransformer(func(value float64) float64 { return value + 1 })
+
+	result, err := Compose(1.0, double, add, double)
+
+	if err != nil || result != 6.0 {
+		t.Errorf("expected 6 and no error, got %v and %v", result, err)
+	}
+
+	first, _ := Compose("pid", double)
+	second, _ := Compose("pid", double)
+
+	if first == nil || first != second {
+		t.Errorf("expected the same subprocess to handle every value, got %v and %v", first, second)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Errorf("expected the subprocess to exit cleanly, got %v", err)
+	}
+
+	if _, err := Compose(1.0, double); !errors.Is(err, ErrProcessClosed) {
+		t.Errorf("expected ErrProcessClosed, got %v", err)
+	}
+}
+
+func TestExecTransformerFailures(t *testing.T) {
+
+	p := helperProcess(t)
+	p.Timeout = 100 * time.Millisecond
+	double := p.Transformer()
+
+	tests := []struct {
+		value any
+		err   error
+		text  string
+	}{
+		{"crash", ErrProcessExited, "exit status 3"},
+		{"garbage", ErrMalformedOutput, `"not json"`},
+		{"hang", ErrTimeout, "after 100ms"},
+	}
+
+	for _, test := range tests {
+
+		before, err := Compose("pid", double)
+
+		if err != nil {
+			t.Fatalf("expected the subprocess to be running, got %v", err)
+		}
+
+		_, err = Compose(test.value, double)
+
+		if !errors.Is(err, test.err) || !strings.Contains(err.Error(), test.text) {
+			t.Errorf("expected %v mentioning %s for %v, got %v", test.err, test.text, test.value, err)
+		}
+
+		if _, ok := err.(*ComposeError); !ok {
+			t.Errorf("expected a *ComposeError for %v, got %T", test.value, err)
+		}
+
+		after, err := Compose("pid", double)
+
+		if err != nil || after == before {
+			t.Errorf("expected the subprocess to have restarted after %v, got %v and %v", test.value, after, err)
+		}
+	}
+
+	if _, err := Compose(func() {}, double); err == nil {
+		t.Errorf("expected an error for a value that cannot be encoded as JSON")
+	}
+}
+
+func TestExecTransformerWriteTimeout(t *testing.T) {
+
+	p := helperProcess(t)
+	p.Timeout = 100 * time.Millisecond
+	t.Setenv("LIB_HELPER_DEAF", "1")
+
+	// Far larger than a pipe's buffer, so writing it blocks until the
+	// subprocess reads it, which it never does.
+	value := strings.Repeat("x", 4*1024*1024)
+	start := time.Now()
+
+	if _, err := Compose(value, p.Transformer()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the write to be abandoned after the timeout, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := ComposeContext(ctx, value, helperProcess(t).Transformer()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestExecTransformerContext(t *testing.T) {
+
+	double := helperProcess(t).Transformer()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := ComposeContext(ctx, "hang", double); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if result, err := Compose(2.0, double); err != nil || result != 4.0 {
+		t.Errorf("expected 4 and no error, got %v and %v", result, err)
+	}
+}
+
+func TestExecTransformerNoCommand(t *testing.T) {
+
+	if _, err := Compose(1, ExecTransformer("no such command for lib tests")); err == nil {
+		t.Errorf("expected an error for a command that does not exist")
+	}
+}
+
+func TestExecTransformerDescribe(t *testing.T) {
+
+	d := Describe(ExecTransformer("python3", "transform.py"))
+
+	if name := d.Children[0].Name; name != "Exec python3 transform.py" {
+		t.Errorf("expected \"Exec python3 transform.py\", got %q", name)
+	}
+}
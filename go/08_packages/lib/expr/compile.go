@@ -0,0 +1,406 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"fmt"
+)
+
+// The values available to an expression while it is being evaluated: the
+// input, in whichever field corresponds to its Type.
+type frame struct {
+	i int
+	f float64
+	c complex128
+	s string
+	b bool
+}
+
+// A type-checked expression, compiled to a function that evaluates it. The
+// function's type is func(*frame) T, where T is the Go type representing typ,
+// so evaluating it requires neither reflection nor boxing values as any.
+type node struct {
+	typ  Type
+	eval any
+}
+
+// Return n's function, which must return a T.
+func evaluator[T value](n node) func(*frame) T {
+	return n.eval.(func(*frame) T)
+}
+
+// Return the node for a function that returns a T.
+func nodeOf[T value](eval func(*frame) T) node {
+	return node{typ: typeOf[T](), eval: eval}
+}
+
+// Type-checks and compiles syntax trees.
+type compiler struct {
+
+	// The name and type of the input variable.
+	name  string
+	input Type
+
+	// The functions that may be called.
+	functions Functions
+}
+
+// Return the compiled form of the given syntax tree, or an *Error if it is not
+// well-typed.
+func (c *compiler) compile(tree syntax) (node, error) {
+
+	switch n := tree.(type) {
+
+	case literal:
+		return constant(n.value), nil
+
+	case variable:
+		if n.name != c.name {
+			return node{}, errorAt(n.at, "undefined: %s (the input is %s)", n.name, c.name)
+		}
+		return c.variable(), nil
+
+	case unary:
+		return c.unary(n)
+
+	case binary:
+		return c.binary(n)
+
+	case conditional:
+		return c.conditional(n)
+
+	default:
+		return c.call(tree.(call))
+	}
+}
+
+// Return the node for a literal value.
+func constant(v any) node {
+
+	switch v := v.(type) {
+	case int:
+		return nodeOf(func(*frame) int { return v })
+	case float64:
+		return nodeOf(func(*frame) float64 { return v })
+	case complex128:
+		return nodeOf(func(*frame) complex128 { return v })
+	case string:
+		return nodeOf(func(*frame) string { return v })
+	default:
+		b := v.(bool)
+		return nodeOf(func(*frame) bool { return b })
+	}
+}
+
+// Return the node for the input variable.
+func (c *compiler) variable() node {
+
+	switch c.input {
+	case Int:
+		return nodeOf(func(fr *frame) int { return fr.i })
+	case Float:
+		return nodeOf(func(fr *frame) float64 { return fr.f })
+	case Complex:
+		return nodeOf(func(fr *frame) complex128 { return fr.c })
+	case String:
+		return nodeOf(func(fr *frame) string { return fr.s })
+	default:
+		return nodeOf(func(fr *frame) bool { return fr.b })
+	}
+}
+
+// Compile a unary operation.
+func (c *compiler) unary(n unary) (node, error) {
+
+	operand, err := c.compile(n.operand)
+
+	if err != nil {
+		return node{}, err
+	}
+
+	switch {
+
+	case n.op == tokenNot && operand.typ == Bool:
+		b := evaluator[bool](operand)
+		return nodeOf(func(fr *frame) bool { return !b(fr) }), nil
+
+	case n.op == tokenMinus && operand.typ == Int:
+		return nodeOf(negate(evaluator[int](operand))), nil
+
+	case n.op == tokenMinus && operand.typ == Float:
+		return nodeOf(negate(evaluator[float64](operand))), nil
+
+	case n.op == tokenMinus && operand.typ == Complex:
+		return nodeOf(negate(evaluator[complex128](operand))), nil
+
+	default:
+		return node{}, errorAt(n.at, "operator %v not defined on %v", n.op, operand.typ)
+	}
+}
+
+// Return a function that negates the result of x.
+func negate[T number](x func(*frame) T) func(*frame) T {
+	return func(fr *frame) T { return -x(fr) }
+}
+
+// Compile a binary operation.
+func (c *compiler) binary(n binary) (node, error) {
+
+	left, err := c.compile(n.left)
+
+	if err != nil {
+		return node{}, err
+	}
+
+	right, err := c.compile(n.right)
+
+	if err != nil {
+		return node{}, err
+	}
+
+	// Numbers of different types are promoted to the wider one; otherwise both
+	// operands must be of the same type.
+	typ, ok := unify(left.typ, right.typ)
+
+	if !ok {
+		return node{}, errorAt(n.at, "mismatched types %v and %v for %v", left.typ, right.typ, n.op)
+	}
+
+	left, right = convert(left, typ), convert(right, typ)
+
+	switch n.op {
+
+	case tokenAnd, tokenOr:
+		if typ == Bool {
+			return logical(n.op, evaluator[bool](left), evaluator[bool](right)), nil
+		}
+
+	case tokenPlus, tokenMinus, tokenStar, tokenSlash:
+		switch {
+		case typ == Int:
+			return nodeOf(arithmetic(n.op, evaluator[int](left), evaluator[int](right))), nil
+		case typ == Float:
+			return nodeOf(arithmetic(n.op, evaluator[float64](left), evaluator[float64](right))), nil
+		case typ == Complex:
+			return nodeOf(arithmetic(n.op, evaluator[complex128](left), evaluator[complex128](right))), nil
+		case typ == String && n.op == tokenPlus:
+			l, r := evaluator[string](left), evaluator[string](right)
+			return nodeOf(func(fr *frame) string { return l(fr) + r(fr) }), nil
+		}
+
+	case tokenPercent:
+		if typ == Int {
+			l, r := evaluator[int](left), evaluator[int](right)
+			return nodeOf(func(fr *frame) int { return l(fr) % r(fr) }), nil
+		}
+
+	case tokenEq, tokenNe:
+		switch typ {
+		case Int:
+			return nodeOf(equality(n.op, evaluator[int](left), evaluator[int](right))), nil
+		case Float:
+			return nodeOf(equality(n.op, evaluator[float64](left), evaluator[float64](right))), nil
+		case Complex:
+			return nodeOf(equality(n.op, evaluator[complex128](left), evaluator[complex128](right))), nil
+		case String:
+			return nodeOf(equality(n.op, evaluator[string](left), evaluator[string](right))), nil
+		case Bool:
+			return nodeOf(equality(n.op, evaluator[bool](left), evaluator[bool](right))), nil
+		}
+
+	default:
+		switch typ {
+		case Int:
+			return nodeOf(ordering(n.op, evaluator[int](left), evaluator[int](right))), nil
+		case Float:
+			return nodeOf(ordering(n.op, evaluator[float64](left), evaluator[float64](right))), nil
+		case String:
+			return nodeOf(ordering(n.op, evaluator[string](left), evaluator[string](right))), nil
+		}
+	}
+
+	return node{}, errorAt(n.at, "operator %v not defined on %v", n.op, typ)
+}
+
+// Return the node for && or ||, which only evaluates right if necessary.
+func logical(op tokenKind, left, right func(*frame) bool) node {
+
+	if op == tokenAnd {
+		return nodeOf(func(fr *frame) bool { return left(fr) && right(fr) })
+	}
+
+	return nodeOf(func(fr *frame) bool { return left(fr) || right(fr) })
+}
+
+// Return a function that applies the given arithmetic operator. As in Go,
+// integer division truncates toward zero and panics if the divisor is zero.
+func arithmetic[T number](op tokenKind, left, right func(*frame) T) func(*frame) T {
+
+	switch op {
+	case tokenPlus:
+		return func(fr *frame) T { return left(fr) + right(fr) }
+	case tokenMinus:
+		return func(fr *frame) T { return left(fr) - right(fr) }
+	case tokenStar:
+		return func(fr *frame) T { return left(fr) * right(fr) }
+	default:
+		return func(fr *frame) T { return left(fr) / right(fr) }
+	}
+}
+
+// Return a function that applies == or !=.
+func equality[T comparable](op tokenKind, left, right func(*frame) T) func(*frame) bool {
+
+	if op == tokenEq {
+		return func(fr *frame) bool { return left(fr) == right(fr) }
+	}
+
+	return func(fr *frame) bool { return left(fr) != right(fr) }
+}
+
+// Return a function that applies <, <=, > or >=.
+func ordering[T ordered](op tokenKind, left, right func(*frame) T) func(*frame) bool {
+
+	switch op {
+	case tokenLt:
+		return func(fr *frame) bool { return left(fr) < right(fr) }
+	case tokenLe:
+		return func(fr *frame) bool { return left(fr) <= right(fr) }
+	case tokenGt:
+		return func(fr *frame) bool { return left(fr) > right(fr) }
+	default:
+		return func(fr *frame) bool { return left(fr) >= right(fr) }
+	}
+}
+
+// Compile a conditional expression.
+func (c *compiler) conditional(n conditional) (node, error) {
+
+	condition, err := c.compile(n.condition)
+
+	if err != nil {
+		return node{}, err
+	}
+
+	if condition.typ != Bool {
+		return node{}, errorAt(n.condition.position(), "condition must be bool, not %v", condition.typ)
+	}
+
+	then, err := c.compile(n.then)
+
+	if err != nil {
+		return node{}, err
+	}
+
+	otherwise, err := c.compile(n.otherwise)
+
+	if err != nil {
+		return node{}, err
+	}
+
+	typ, ok := unify(then.typ, otherwise.typ)
+
+	if !ok {
+		return node{}, errorAt(n.at, "mismatched types %v and %v for the branches of if", then.typ, otherwise.typ)
+	}
+
+	b := evaluator[bool](condition)
+	then, otherwise = convert(then, typ), convert(otherwise, typ)
+
+	switch typ {
+	case Int:
+		return nodeOf(choose(b, evaluator[int](then), evaluator[int](otherwise))), nil
+	case Float:
+		return nodeOf(choose(b, evaluator[float64](then), evaluator[float64](otherwise))), nil
+	case Complex:
+		return nodeOf(choose(b, evaluator[complex128](then), evaluator[complex128](otherwise))), nil
+	case String:
+		return nodeOf(choose(b, evaluator[string](then), evaluator[string](otherwise))), nil
+	default:
+		return nodeOf(choose(b, evaluator[bool](then), evaluator[bool](otherwise))), nil
+	}
+}
+
+// Return a function that evaluates then if condition is true, otherwise
+// otherwise.
+func choose[T value](condition func(*frame) bool, then, otherwise func(*frame) T) func(*frame) T {
+
+	return func(fr *frame) T {
+
+		if condition(fr) {
+			return then(fr)
+		}
+
+		return otherwise(fr)
+	}
+}
+
+// Compile a function call.
+func (c *compiler) call(n call) (node, error) {
+
+	args := make([]node, len(n.args))
+	types := make([]Type, len(n.args))
+
+	for i, arg := range n.args {
+
+		compiled, err := c.compile(arg)
+
+		if err != nil {
+			return node{}, err
+		}
+
+		args[i], types[i] = compiled, compiled.typ
+	}
+
+	f, err := c.functions.resolve(n.name, types)
+
+	if err != nil {
+		return node{}, errorAt(n.at, "%v", err)
+	}
+
+	for i, param := range f.params {
+		args[i] = convert(args[i], param)
+	}
+
+	return f.build(args), nil
+}
+
+// Return the type to which values of types a and b are both converted when
+// they are combined, and true, or false if they cannot be combined.
+func unify(a, b Type) (Type, bool) {
+
+	switch {
+	case a.promotes(b):
+		return b, true
+	case b.promotes(a):
+		return a, true
+	default:
+		return a, false
+	}
+}
+
+// Return n converted to the given type, to which it must promote.
+func convert(n node, to Type) node {
+
+	if n.typ == to {
+		return n
+	}
+
+	switch {
+
+	case n.typ == Int && to == Float:
+		x := evaluator[int](n)
+		return nodeOf(func(fr *frame) float64 { return float64(x(fr)) })
+
+	case n.typ == Int && to == Complex:
+		x := evaluator[int](n)
+		return nodeOf(func(fr *frame) complex128 { return complex(float64(x(fr)), 0) })
+
+	case n.typ == Float && to == Complex:
+		x := evaluator[float64](n)
+		return nodeOf(func(fr *frame) complex128 { return complex(x(fr), 0) })
+
+	default:
+		panic(fmt.Sprintf("cannot convert %v to %v", n.typ, to))
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"errors"
+	"testing"
+)
+
+// Return the result of evaluating source with the given input.
+func evaluate(t *testing.T, source string, input any) any {
+
+	t.Helper()
+
+	var typ Type
+
+	switch input.(type) {
+	case int:
+		typ = Int
+	case float64:
+		typ = Float
+	case complex128:
+		typ = Complex
+	case string:
+		typ = String
+	default:
+		typ = Bool
+	}
+
+	transformer, err := Compile(source, typ, nil)
+
+	if err != nil {
+		t.Fatalf("%q: %v", source, err)
+	}
+
+	return transformer(input)
+}
+
+func TestCompile(t *testing.T) {
+
+	for _, test := range []struct {
+		source   string
+		input    any
+		expected any
+	}{
+		{"x * 2 + 1", 20, 41},
+		{"x / 2", 7, 3},
+		{"x / 2", 7.0, 3.5},
+		{"x % 3", -7, -1},
+		{"x + 0.5", 1, 1.5},
+		{"x * 1i", 2, 2i},
+		{"x + 1i", 0.5, 0.5 + 1i},
+		{"-x", 3.5, -3.5},
+		{"-x", 2i, -2i},
+		{"if x > 10 then x - 10 else x", 15, 5},
+		{"if x > 10 then x - 10 else x", 5, 5},
+		{"if x then 1 else 2.5", true, 1.0},
+		{"x + \"!\"", "hi", "hi!"},
+		{"x < \"b\"", "a", true},
+		{"x == 2", 2.0, true},
+		{"x != 1i", 1i, false},
+		{"!x && true", false, true},
+		{"x || 1 / 0 == 0", true, true},
+		{"x >= 3 && x <= 5", 4, true},
+		{"x == x", true, true},
+	} {
+
+		if result := evaluate(t, test.source, test.input); result != test.expected {
+			t.Errorf("%q with %v: expected %v of type %T, got %v of type %T",
+				test.source, test.input, test.expected, test.expected, result, result)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+
+	for _, test := range []struct {
+		source   string
+		input    Type
+		expected Error
+	}{
+		{"y + 1", Int, Error{1, 1, "undefined: y (the input is x)"}},
+		{"x + 1", String, Error{1, 3, "mismatched types string and int for +"}},
+		{"x - \"a\"", String, Error{1, 3, "operator - not defined on string"}},
+		{"x % 2", Float, Error{1, 3, "operator % not defined on float"}},
+		{"x < 1i", Complex, Error{1, 3, "operator < not defined on complex"}},
+		{"x && 1", Bool, Error{1, 3, "mismatched types bool and int for &&"}},
+		{"-x", String, Error{1, 1, "operator - not defined on string"}},
+		{"!x", Int, Error{1, 1, "operator ! not defined on int"}},
+		{"if x then 1 else 2", Int, Error{1, 4, "condition must be bool, not int"}},
+		{"if x then 1 else \"a\"", Bool, Error{1, 1, "mismatched types int and string for the branches of if"}},
+		{"nope(x)", Int, Error{1, 1, "undefined function nope"}},
+		{"\n  upper(x)", Int, Error{2, 3, "no upper function accepts (int)"}},
+		{"x", Type(42), Error{1, 1, "invalid input type <Type 42>"}},
+		{"x", Type(-1), Error{1, 1, "invalid input type <Type -1>"}},
+	} {
+
+		_, err := Compile(test.source, test.input, nil)
+
+		var e *Error
+
+		if !errors.As(err, &e) || *e != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.source, &test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright Kirk Rader 2024
+
+// Package expr compiles a small expression language to lib.Transformer
+// functions, so that simple stages can be written in configuration files
+// rather than in Go, e.g.:
+//
+//	x * 2 + 1
+//	if x > 10 then x - 10 else x
+//	upper(trim(x)) + "!"
+//
+// An expression refers to the value passed to its Transformer as x, whose Type
+// is declared when the expression is compiled. Expressions are statically
+// typed: every expression is checked before it is run, and has a single Type
+// which is that of the value its Transformer returns.
+//
+// The Types are int, float, complex, string and bool, represented by Go's int,
+// float64, complex128, string and bool. Numbers follow the int, float and
+// complex split of the Integer, Float and Complex constraints in
+// ../../../05_generics/generics.go: an int can be used where a float or
+// complex is expected, and a float where a complex is, so that 1 + 0.5 is a
+// float, but not the other way around, so the result of an arithmetic
+// operation is of the wider of its operands' Types. Use the int() and float()
+// functions to convert explicitly.
+//
+// The operators, from lowest to highest precedence, are:
+//
+//	||                      bool
+//	&&                      bool
+//	== !=                   any two values of the same Type, after promotion
+//	< <= > >=               int, float or string
+//	+ -                     numbers; + also concatenates strings
+//	* / %                   numbers, except that % is only for ints
+//	- !                     unary negation of a number, or not of a bool
+//
+// along with if condition then value else value, calls to Functions, e.g.
+// upper(x), parentheses and literals such as 42, 1.5, 2e3, 3i, "text" with Go
+// escapes, `raw text`, true and false.
+package expr
+
+import (
+	"fmt"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+// The name by which an expression refers to its input.
+const Input = "x"
+
+// Error in the source of an expression, reported by Compile().
+type Error struct {
+
+	// Position in the source at which the error was detected; both start at 1
+	// and Column counts characters, not bytes.
+	Line, Column int
+
+	// Description of the error.
+	Message string
+}
+
+// Implement the error interface, e.g. "1:5: undefined: y (the input is x)".
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Return an *Error at the given position.
+func errorAt(at position, format string, args ...any) error {
+	return &Error{Line: at.line, Column: at.column, Message: fmt.Sprintf(format, args...)}
+}
+
+// Return a Transformer that evaluates the given expression with its argument,
+// which must be of the given Type, as x, or an *Error if the expression is
+// malformed or not well-typed, or if input is not one of the Types declared by
+// this package. The expression may call the given Functions; if functions is
+// nil, it may call those returned by Builtins().
+//
+// The Transformer accepts and returns the Go types representing input and the
+// expression's Type, as far as lib.Validate() is concerned, and is described
+// by lib.Describe() using the expression's source.
+//
+// The expression is compiled to a tree of Go closures, each of which returns
+// a value of the specific Go type of the corresponding subexpression, so
+// evaluating it involves neither reflection nor interpretation of the syntax.
+func Compile(source string, input Type, functions Functions) (lib.Transformer, error) {
+
+	if !input.valid() {
+		return nil, errorAt(position{line: 1, column: 1}, "invalid input type %v", input)
+	}
+
+	if functions == nil {
+		functions = Builtins()
+	}
+
+	tree, err := parse(source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compiler{name: Input, input: input, functions: functions}
+	result, err := c.compile(tree)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lib.Named(source, transformer(input, result)), nil
+}
+
+// Return the Transformer that evaluates result with its argument, of the
+// given Type, as the input.
+func transformer(input Type, result node) lib.Transformer {
+
+	switch input {
+	case Int:
+		return withInput(result, func(fr *frame, x int) { fr.i = x })
+	case Float:
+		return withInput(result, func(fr *frame, x float64) { fr.f = x })
+	case Complex:
+		return withInput(result, func(fr *frame, x complex128) { fr.c = x })
+	case String:
+		return withInput(result, func(fr *frame, x string) { fr.s = x })
+	default:
+		return withInput(result, func(fr *frame, x bool) { fr.b = x })
+	}
+}
+
+// Like transformer(), once the Go type of the input is known.
+func withInput[I value](result node, set func(*frame, I)) lib.Transformer {
+
+	switch result.typ {
+	case Int:
+		return mapper(set, evaluator[int](result))
+	case Float:
+		return mapper(set, evaluator[float64](result))
+	case Complex:
+		return mapper(set, evaluator[complex128](result))
+	case String:
+		return mapper(set, evaluator[string](result))
+	default:
+		return mapper(set, evaluator[bool](result))
+	}
+}
+
+// Like transformer(), once the Go types of both the input and the result are
+// known.
+func mapper[I, O value](set func(*frame, I), eval func(*frame) O) lib.Transformer {
+
+	return lib.MakeMapper(func(x I) O {
+
+		fr := &frame{}
+		set(fr, x)
+		return eval(fr)
+	})
+}
@@ -0,0 +1,73 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"strings"
+	"testing"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+func TestCompileTransformer(t *testing.T) {
+
+	double, err := Compile("x * 2 + 1", Int, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	describe, err := Compile("if x > 100 then \"big\" else \"small\"", Int, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result, err := lib.ComposeOf(10, double, double); err != nil || result != 43 {
+		t.Errorf("expected 43 and no error, got %v and %v", result, err)
+	}
+
+	if result, err := lib.Compose(60, double, describe); err != nil || result != "big" {
+		t.Errorf("expected big and no error, got %v and %v", result, err)
+	}
+
+	if err := lib.Validate(1, describe, double); err == nil {
+		t.Errorf("expected a *lib.TypeError since describe returns a string")
+	}
+
+	text := lib.Describe(double, describe).String()
+
+	if !strings.Contains(text, "0: x * 2 + 1 (func(int) int)") {
+		t.Errorf("expected the source to be used as the name, got\n%s", text)
+	}
+}
+
+func TestCompileRuntimeErrors(t *testing.T) {
+
+	divide, err := Compile("100 / x", Int, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = lib.Compose(0, divide)
+
+	if composeError, ok := err.(*lib.ComposeError); !ok || !composeError.IsRuntimeError() {
+		t.Errorf("expected a runtime error, got %v", err)
+	}
+
+	parse, err := Compile("int(x)", String, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := lib.Compose("one", parse); err == nil {
+		t.Errorf("expected an error")
+	}
+
+	// The input must be of the declared type.
+	if _, err := lib.Compose(1.5, divide); err == nil {
+		t.Errorf("expected an error")
+	}
+}
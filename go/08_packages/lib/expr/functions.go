@@ -0,0 +1,225 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Go function that can be called from expressions. Create using Func0(),
+// Func1(), Func2() or Func3().
+//
+// A Function may panic, e.g. when its argument is invalid, in which case the
+// Transformer evaluating the expression fails as any other Transformer that
+// panics does.
+type Function struct {
+
+	// The types of the parameters.
+	params []Type
+
+	// The type of the result.
+	result Type
+
+	// Return the node that calls the function with the given arguments, whose
+	// types have already been converted to params.
+	build func(args []node) node
+}
+
+// Return the Function that calls f, which takes no arguments.
+func Func0[R value](f func() R) Function {
+
+	return Function{
+		result: typeOf[R](),
+		build: func([]node) node {
+			return node{typ: typeOf[R](), eval: func(*frame) R { return f() }}
+		},
+	}
+}
+
+// Return the Function that calls f, which takes one argument.
+func Func1[A, R value](f func(A) R) Function {
+
+	return Function{
+		params: []Type{typeOf[A]()},
+		result: typeOf[R](),
+		build: func(args []node) node {
+			a := evaluator[A](args[0])
+			return node{typ: typeOf[R](), eval: func(fr *frame) R { return f(a(fr)) }}
+		},
+	}
+}
+
+// Return the Function that calls f, which takes two arguments.
+func Func2[A, B, R value](f func(A, B) R) Function {
+
+	return Function{
+		params: []Type{typeOf[A](), typeOf[B]()},
+		result: typeOf[R](),
+		build: func(args []node) node {
+			a, b := evaluator[A](args[0]), evaluator[B](args[1])
+			return node{typ: typeOf[R](), eval: func(fr *frame) R { return f(a(fr), b(fr)) }}
+		},
+	}
+}
+
+// Return the Function that calls f, which takes three arguments.
+func Func3[A, B, C, R value](f func(A, B, C) R) Function {
+
+	return Function{
+		params: []Type{typeOf[A](), typeOf[B](), typeOf[C]()},
+		result: typeOf[R](),
+		build: func(args []node) node {
+			a, b, c := evaluator[A](args[0]), evaluator[B](args[1]), evaluator[C](args[2])
+			return node{typ: typeOf[R](), eval: func(fr *frame) R { return f(a(fr), b(fr), c(fr)) }}
+		},
+	}
+}
+
+// Implement fmt.Stringer, e.g. "func(int, int) int".
+func (f Function) String() string {
+
+	params := make([]string, len(f.params))
+
+	for i, param := range f.params {
+		params[i] = param.String()
+	}
+
+	return fmt.Sprintf("func(%s) %v", strings.Join(params, ", "), f.result)
+}
+
+// Return true if and only if f can be called with arguments of the given
+// types, allowing numeric arguments to be promoted if promote is true.
+func (f Function) accepts(args []Type, promote bool) bool {
+
+	if len(args) != len(f.params) {
+		return false
+	}
+
+	for i, arg := range args {
+		if arg != f.params[i] && !(promote && arg.promotes(f.params[i])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// The functions that can be called from expressions, indexed by name.
+//
+// A name may have more than one Function, i.e. be overloaded, in which case a
+// call is resolved to the first Function whose parameter types match its
+// arguments' types exactly or, if there is none, to the first Function whose
+// parameters the arguments can be promoted to. For example, given abs(int) int
+// and abs(float) float, abs(1) is an int and abs(1.5) a float, while given
+// only the latter, abs(1) would be a float.
+type Functions map[string][]Function
+
+// Add Functions under the given name, after any already defined for it.
+func (fs Functions) Define(name string, overloads ...Function) {
+	fs[name] = append(fs[name], overloads...)
+}
+
+// Return the Function to call for the given name and argument types.
+func (fs Functions) resolve(name string, args []Type) (Function, error) {
+
+	overloads, ok := fs[name]
+
+	if !ok {
+		return Function{}, fmt.Errorf("undefined function %s", name)
+	}
+
+	for _, promote := range []bool{false, true} {
+		for _, f := range overloads {
+			if f.accepts(args, promote) {
+				return f, nil
+			}
+		}
+	}
+
+	types := make([]string, len(args))
+
+	for i, arg := range args {
+		types[i] = arg.String()
+	}
+
+	return Function{}, fmt.Errorf("no %s function accepts (%s)", name, strings.Join(types, ", "))
+}
+
+// Return a new table of the functions available by default, to which others
+// can be added using Define():
+//
+//	len(string) int: the number of characters (not bytes)
+//	upper(string) string, lower(string) string, trim(string) string
+//	contains(string, string) bool, hasPrefix(string, string) bool,
+//	hasSuffix(string, string) bool
+//	replace(s string, old string, new string) string: replaces every old
+//	abs(int) int, abs(float) float, abs(complex) float
+//	min(int, int) int, min(float, float) float, and likewise max
+//	sqrt(float) float, pow(float, float) float, floor(float) float,
+//	ceil(float) float, round(float) float
+//	real(complex) float, imag(complex) float, complex(float, float) complex
+//	int(float) int: truncates toward zero
+//	int(string) int, float(string) float: parse, panicking on failure
+//	float(int) float, string(int) string, string(float) string,
+//	string(complex) string, string(bool) string
+func Builtins() Functions {
+
+	fs := Functions{}
+
+	fs.Define("len", Func1(utf8.RuneCountInString))
+	fs.Define("upper", Func1(strings.ToUpper))
+	fs.Define("lower", Func1(strings.ToLower))
+	fs.Define("trim", Func1(strings.TrimSpace))
+	fs.Define("contains", Func2(strings.Contains))
+	fs.Define("hasPrefix", Func2(strings.HasPrefix))
+	fs.Define("hasSuffix", Func2(strings.HasSuffix))
+	fs.Define("replace", Func3(strings.ReplaceAll))
+
+	fs.Define("abs",
+		Func1(func(x int) int { return max(x, -x) }),
+		Func1(math.Abs),
+		Func1(cmplx.Abs))
+
+	fs.Define("min", Func2(func(x, y int) int { return min(x, y) }), Func2(math.Min))
+	fs.Define("max", Func2(func(x, y int) int { return max(x, y) }), Func2(math.Max))
+	fs.Define("sqrt", Func1(math.Sqrt))
+	fs.Define("pow", Func2(math.Pow))
+	fs.Define("floor", Func1(math.Floor))
+	fs.Define("ceil", Func1(math.Ceil))
+	fs.Define("round", Func1(math.Round))
+
+	fs.Define("real", Func1(func(z complex128) float64 { return real(z) }))
+	fs.Define("imag", Func1(func(z complex128) float64 { return imag(z) }))
+	fs.Define("complex", Func2(func(x, y float64) complex128 { return complex(x, y) }))
+
+	fs.Define("int",
+		Func1(func(x float64) int { return int(x) }),
+		Func1(func(s string) int { return must(strconv.Atoi(s)) }))
+
+	fs.Define("float",
+		Func1(func(x int) float64 { return float64(x) }),
+		Func1(func(s string) float64 { return must(strconv.ParseFloat(s, 64)) }))
+
+	fs.Define("string",
+		Func1(strconv.Itoa),
+		Func1(func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }),
+		Func1(func(z complex128) string { return strconv.FormatComplex(z, 'g', -1, 128) }),
+		Func1(strconv.FormatBool))
+
+	return fs
+}
+
+// Return value, or panic with err if it is not nil.
+func must[T any](value T, err error) T {
+
+	if err != nil {
+		panic(err)
+	}
+
+	return value
+}
@@ -0,0 +1,72 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltins(t *testing.T) {
+
+	for _, test := range []struct {
+		source   string
+		input    any
+		expected any
+	}{
+		{"upper(trim(x)) + \"!\"", "  hi ", "HI!"},
+		{"len(x)", "héllo", 5},
+		{"contains(x, \"ll\") && hasPrefix(x, \"h\") && !hasSuffix(x, \"h\")", "hello", true},
+		{"replace(x, \"l\", \"L\")", "hello", "heLLo"},
+		{"abs(x)", -3, 3},
+		{"abs(x)", -3.5, 3.5},
+		{"abs(x)", 3 + 4i, 5.0},
+		{"min(x, 2)", 5, 2},
+		{"max(x, 2.5)", 1, 2.5},
+		{"sqrt(x)", 16, 4.0},
+		{"pow(x, 2)", 3, 9.0},
+		{"floor(x) + ceil(x) + round(x)", 1.5, 5.0},
+		{"real(x) + imag(x)", 1 + 2i, 3.0},
+		{"complex(x, 1)", 2, 2 + 1i},
+		{"int(x)", -2.7, -2},
+		{"int(x) + 1", "41", 42},
+		{"float(x) / 2", 3, 1.5},
+		{"float(x)", "2.5", 2.5},
+		{"string(x) + string(x > 1) + string(1.5) + string(1i)", 2, "2true1.5(0+1i)"},
+	} {
+
+		if result := evaluate(t, test.source, test.input); result != test.expected {
+			t.Errorf("%q with %v: expected %v of type %T, got %v of type %T",
+				test.source, test.input, test.expected, test.expected, result, result)
+		}
+	}
+}
+
+func TestDefine(t *testing.T) {
+
+	functions := Builtins()
+	functions.Define("repeat", Func2(strings.Repeat))
+	functions.Define("answer", Func0(func() int { return 42 }))
+
+	// An overload for ints is preferred over the built-in one for floats,
+	// since it needs no promotion.
+	functions.Define("sqrt", Func1(func(x int) int { return -1 }))
+
+	transformer, err := Compile("repeat(x, answer() / 21) + string(sqrt(4) + sqrt(4.0))", String, functions)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := transformer("ab"); result != "abab1" {
+		t.Errorf("expected abab1, got %v", result)
+	}
+
+	if _, err := Compile("repeat(x, 2)", String, nil); err == nil {
+		t.Errorf("expected repeat() to be undefined by default")
+	}
+
+	if s := Func3(strings.ReplaceAll).String(); s != "func(string, string, string) string" {
+		t.Errorf("expected func(string, string, string) string, got %s", s)
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The kinds of token produced by the lexer.
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenFloat
+	tokenImag
+	tokenString
+	tokenIf
+	tokenThen
+	tokenElse
+	tokenTrue
+	tokenFalse
+	tokenPlus
+	tokenMinus
+	tokenStar
+	tokenSlash
+	tokenPercent
+	tokenEq
+	tokenNe
+	tokenLt
+	tokenLe
+	tokenGt
+	tokenGe
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+// How each kind of token is described in error messages, indexed by tokenKind.
+var tokenNames = []string{
+	"end of expression", "identifier", "integer", "floating-point number",
+	"imaginary number", "string", "if", "then", "else", "true", "false",
+	"+", "-", "*", "/", "%", "==", "!=", "<", "<=", ">", ">=", "&&", "||", "!",
+	"(", ")", ",",
+}
+
+// Implement fmt.Stringer.
+func (k tokenKind) String() string {
+	return tokenNames[k]
+}
+
+// Keywords, which are otherwise lexed as identifiers.
+var keywords = map[string]tokenKind{
+	"if":    tokenIf,
+	"then":  tokenThen,
+	"else":  tokenElse,
+	"true":  tokenTrue,
+	"false": tokenFalse,
+}
+
+// Operators, longest first so that, e.g., "<=" is not lexed as "<" and "=".
+var operators = []struct {
+	text string
+	kind tokenKind
+}{
+	{"==", tokenEq}, {"!=", tokenNe}, {"<=", tokenLe}, {">=", tokenGe},
+	{"&&", tokenAnd}, {"||", tokenOr},
+	{"+", tokenPlus}, {"-", tokenMinus}, {"*", tokenStar}, {"/", tokenSlash},
+	{"%", tokenPercent}, {"<", tokenLt}, {">", tokenGt}, {"!", tokenNot},
+	{"(", tokenLParen}, {")", tokenRParen}, {",", tokenComma},
+}
+
+// A position in the source of an expression; both line and column start at 1
+// and columns count runes, not bytes.
+type position struct {
+	line, column int
+}
+
+// A token, with its value if it is a literal.
+type token struct {
+	kind  tokenKind
+	text  string
+	value any
+	at    position
+}
+
+// Splits the source of an expression into tokens.
+type lexer struct {
+	source string
+	offset int
+	at     position
+}
+
+// Return a lexer positioned at the start of source.
+func newLexer(source string) *lexer {
+	return &lexer{source: source, at: position{line: 1, column: 1}}
+}
+
+// Return all of the tokens in l's source, ending with a tokenEOF, or the
+// first error.
+func (l *lexer) tokens() ([]token, error) {
+
+	tokens := []token{}
+
+	for {
+
+		t, err := l.next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, t)
+
+		if t.kind == tokenEOF {
+			return tokens, nil
+		}
+	}
+}
+
+// Return the next token.
+func (l *lexer) next() (token, error) {
+
+	l.skipSpace()
+
+	start := l.at
+	rest := l.source[l.offset:]
+
+	if rest == "" {
+		return token{kind: tokenEOF, at: start}, nil
+	}
+
+	r, _ := utf8.DecodeRuneInString(rest)
+
+	switch {
+
+	case r == '_' || unicode.IsLetter(r):
+		text := l.take(func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) })
+
+		if kind, ok := keywords[text]; ok {
+			return token{kind: kind, text: text, at: start}, nil
+		}
+
+		return token{kind: tokenIdent, text: text, at: start}, nil
+
+	case unicode.IsDigit(r) || r == '.' && len(rest) > 1 && unicode.IsDigit(rune(rest[1])):
+		return l.number(start)
+
+	case r == '"' || r == '`':
+		return l.string(start, r)
+	}
+
+	for _, operator := range operators {
+		if strings.HasPrefix(rest, operator.text) {
+			l.advance(len(operator.text))
+			return token{kind: operator.kind, text: operator.text, at: start}, nil
+		}
+	}
+
+	return token{}, errorAt(start, "unexpected %q", r)
+}
+
+// Skip white space.
+func (l *lexer) skipSpace() {
+	l.take(unicode.IsSpace)
+}
+
+// Consume and return the longest prefix of the remaining source whose runes
+// satisfy the given predicate.
+func (l *lexer) take(predicate func(rune) bool) string {
+
+	start := l.offset
+
+	for l.offset < len(l.source) {
+
+		r, size := utf8.DecodeRuneInString(l.source[l.offset:])
+
+		if !predicate(r) {
+			break
+		}
+
+		l.advance(size)
+	}
+
+	return l.source[start:l.offset]
+}
+
+// Consume the given number of bytes, which must end at a rune boundary,
+// keeping track of the line and column.
+func (l *lexer) advance(size int) {
+
+	for _, r := range l.source[l.offset : l.offset+size] {
+
+		if r == '\n' {
+			l.at.line += 1
+			l.at.column = 1
+		} else {
+			l.at.column += 1
+		}
+	}
+
+	l.offset += size
+}
+
+// Consume a numeric literal: an integer, a floating-point number with a
+// fraction and/or exponent, or either of those followed by i to make it
+// imaginary, as in Go.
+func (l *lexer) number(start position) (token, error) {
+
+	digits := unicode.IsDigit
+	begin := l.offset
+	kind := tokenInt
+
+	l.take(digits)
+
+	if l.peek() == '.' {
+		kind = tokenFloat
+		l.advance(1)
+		l.take(digits)
+	}
+
+	if r := l.peek(); r == 'e' || r == 'E' {
+
+		kind = tokenFloat
+		l.advance(1)
+
+		if r := l.peek(); r == '+' || r == '-' {
+			l.advance(1)
+		}
+
+		if exponent := l.take(digits); exponent == "" {
+			return token{}, errorAt(start, "malformed exponent in %q", l.source[begin:l.offset])
+		}
+	}
+
+	if l.peek() == 'i' {
+		kind = tokenImag
+		l.advance(1)
+	}
+
+	text := l.source[begin:l.offset]
+
+	if r := l.peek(); r == '_' || unicode.IsLetter(r) {
+		return token{}, errorAt(start, "malformed number %q", text+string(r))
+	}
+
+	t := token{kind: kind, text: text, at: start}
+	var err error
+
+	switch kind {
+	case tokenInt:
+		var integer int64
+		integer, err = strconv.ParseInt(text, 10, strconv.IntSize)
+		t.value = int(integer)
+	case tokenFloat:
+		t.value, err = strconv.ParseFloat(text, 64)
+	case tokenImag:
+		var imaginary float64
+		imaginary, err = strconv.ParseFloat(strings.TrimSuffix(text, "i"), 64)
+		t.value = complex(0, imaginary)
+	}
+
+	if err != nil {
+		return token{}, errorAt(start, "malformed number %q", text)
+	}
+
+	return t, nil
+}
+
+// Consume a string literal delimited by the given quote, which is either " for
+// an interpreted string literal or ` for a raw one, as in Go.
+func (l *lexer) string(start position, quote rune) (token, error) {
+
+	begin := l.offset
+	l.advance(1)
+
+	for {
+
+		r := l.peek()
+
+		switch {
+
+		case r == utf8.RuneError || quote == '"' && r == '\n':
+			return token{}, errorAt(start, "unterminated string")
+
+		case r == quote:
+			l.advance(1)
+			text := l.source[begin:l.offset]
+			value, err := strconv.Unquote(text)
+
+			if err != nil {
+				return token{}, errorAt(start, "malformed string %s", text)
+			}
+
+			return token{kind: tokenString, text: text, value: value, at: start}, nil
+
+		case r == '\\' && quote == '"':
+			l.advance(1)
+
+			if l.peek() == utf8.RuneError {
+				return token{}, errorAt(start, "unterminated string")
+			}
+
+			l.advance(utf8.RuneLen(l.peek()))
+
+		default:
+			l.advance(utf8.RuneLen(r))
+		}
+	}
+}
+
+// Return the next rune without consuming it, or utf8.RuneError at the end of
+// the source.
+func (l *lexer) peek() rune {
+
+	if l.offset >= len(l.source) {
+		return utf8.RuneError
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.source[l.offset:])
+	return r
+}
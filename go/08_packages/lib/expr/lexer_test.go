@@ -0,0 +1,69 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLexer(t *testing.T) {
+
+	tokens, err := newLexer("if x >= 1.5e1 then\n  \"a\\tb\" + `c` else -3i").tokens()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		kind         tokenKind
+		value        any
+		line, column int
+	}{
+		{tokenIf, nil, 1, 1},
+		{tokenIdent, nil, 1, 4},
+		{tokenGe, nil, 1, 6},
+		{tokenFloat, 15.0, 1, 9},
+		{tokenThen, nil, 1, 15},
+		{tokenString, "a\tb", 2, 3},
+		{tokenPlus, nil, 2, 10},
+		{tokenString, "c", 2, 12},
+		{tokenElse, nil, 2, 16},
+		{tokenMinus, nil, 2, 21},
+		{tokenImag, 3i, 2, 22},
+		{tokenEOF, nil, 2, 24},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("expected %d tokens, got %v", len(expected), tokens)
+	}
+
+	for i, e := range expected {
+
+		token := tokens[i]
+
+		if token.kind != e.kind || e.value != nil && token.value != e.value || token.at != (position{e.line, e.column}) {
+			t.Errorf("%d: expected %v %v at %d:%d, got %+v", i, e.kind, e.value, e.line, e.column, token)
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+
+	for source, expected := range map[string]Error{
+		"x # 1":                {1, 3, `unexpected '#'`},
+		"\"abc":                {1, 1, "unterminated string"},
+		"1 +\n 12abc":          {2, 2, `malformed number "12a"`},
+		"2e+":                  {1, 1, `malformed exponent in "2e+"`},
+		"99999999999999999999": {1, 1, `malformed number "99999999999999999999"`},
+	} {
+
+		_, err := newLexer(source).tokens()
+
+		var e *Error
+
+		if !errors.As(err, &e) || *e != expected {
+			t.Errorf("%q: expected %v, got %v", source, &expected, err)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"slices"
+)
+
+// A node of the abstract syntax tree produced by the parser.
+type syntax interface {
+
+	// Return the position in the source at which the node starts, or, for a
+	// binary operation, of its operator.
+	position() position
+}
+
+// A literal value: an int, float64, complex128, string or bool.
+type literal struct {
+	at    position
+	value any
+}
+
+// A reference to a variable.
+type variable struct {
+	at   position
+	name string
+}
+
+// An operator applied to a single operand, e.g. -x.
+type unary struct {
+	at      position
+	op      tokenKind
+	operand syntax
+}
+
+// An operator applied to two operands, e.g. x + 1.
+type binary struct {
+	at          position
+	op          tokenKind
+	left, right syntax
+}
+
+// if condition then value else otherwise.
+type conditional struct {
+	at                         position
+	condition, then, otherwise syntax
+}
+
+// A call to a function, e.g. upper(x).
+type call struct {
+	at   position
+	name string
+	args []syntax
+}
+
+func (n literal) position() position     { return n.at }
+func (n variable) position() position    { return n.at }
+func (n unary) position() position       { return n.at }
+func (n binary) position() position      { return n.at }
+func (n conditional) position() position { return n.at }
+func (n call) position() position        { return n.at }
+
+// Binary operators, from the lowest precedence to the highest.
+var precedence = [][]tokenKind{
+	{tokenOr},
+	{tokenAnd},
+	{tokenEq, tokenNe},
+	{tokenLt, tokenLe, tokenGt, tokenGe},
+	{tokenPlus, tokenMinus},
+	{tokenStar, tokenSlash, tokenPercent},
+}
+
+// Recursive-descent parser for the grammar:
+//
+//	expression = binary(0) .
+//	binary(n)  = binary(n+1) { operator(n) binary(n+1) } .
+//	binary(6)  = unary .
+//	unary      = ( "-" | "!" ) unary | primary .
+//	primary    = literal | identifier [ "(" [ expression { "," expression } ] ")" ]
+//	           | "if" expression "then" expression "else" expression
+//	           | "(" expression ")" .
+//
+// Since the else branch of a conditional extends as far as possible, like a
+// lambda in other languages, 1 + if c then 2 else 3 + 4 adds 4 to the else
+// branch rather than to the result of the conditional.
+//
+// where operator(n) is any of the operators in precedence[n]. As in Go, the
+// equality operators have lower precedence than the others, so that x < y ==
+// true compares the result of x < y with true, but operators of the same
+// precedence do not associate, so x < y < z and x == y != z are errors, as
+// they would be in Go for any type but bool.
+type parser struct {
+	tokens []token
+	next   int
+}
+
+// Return the syntax tree for the given source.
+func parse(source string) (syntax, error) {
+
+	tokens, err := newLexer(source).tokens()
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	tree, err := p.expression()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorAt(t.at, "unexpected %s after expression", describeToken(t))
+	}
+
+	return tree, nil
+}
+
+// Return the next token without consuming it.
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+// Consume and return the next token.
+func (p *parser) take() token {
+
+	t := p.tokens[p.next]
+
+	if t.kind != tokenEOF {
+		p.next += 1
+	}
+
+	return t
+}
+
+// Consume the next token, which must be of the given kind.
+func (p *parser) expect(kind tokenKind) (token, error) {
+
+	t := p.take()
+
+	if t.kind != kind {
+		return t, errorAt(t.at, "expected %s, found %s", kind, describeToken(t))
+	}
+
+	return t, nil
+}
+
+// Parse an expression.
+func (p *parser) expression() (syntax, error) {
+	return p.binary(0)
+}
+
+// Parse a conditional expression, starting with the "if".
+func (p *parser) conditional() (syntax, error) {
+
+	at := p.take().at
+	condition, err := p.expression()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(tokenThen); err != nil {
+		return nil, err
+	}
+
+	then, err := p.expression()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(tokenElse); err != nil {
+		return nil, err
+	}
+
+	otherwise, err := p.expression()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return conditional{at: at, condition: condition, then: then, otherwise: otherwise}, nil
+}
+
+// Parse a sequence of operations whose operators have the given precedence or
+// higher.
+func (p *parser) binary(level int) (syntax, error) {
+
+	if level == len(precedence) {
+		return p.unary()
+	}
+
+	left, err := p.binary(level + 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+
+		t := p.peek()
+
+		if !slices.Contains(precedence[level], t.kind) {
+			return left, nil
+		}
+
+		p.take()
+		right, err := p.binary(level + 1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		left = binary{at: t.at, op: t.kind, left: left, right: right}
+
+		if isComparison(t.kind) && slices.Contains(precedence[level], p.peek().kind) {
+			return nil, errorAt(p.peek().at, "comparisons cannot be chained")
+		}
+	}
+}
+
+// Parse a unary operation or a primary expression.
+func (p *parser) unary() (syntax, error) {
+
+	t := p.peek()
+
+	if t.kind != tokenMinus && t.kind != tokenNot {
+		return p.primary()
+	}
+
+	p.take()
+	operand, err := p.unary()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return unary{at: t.at, op: t.kind, operand: operand}, nil
+}
+
+// Parse a literal, variable, function call, conditional or parenthesized
+// expression.
+func (p *parser) primary() (syntax, error) {
+
+	if p.peek().kind == tokenIf {
+		return p.conditional()
+	}
+
+	t := p.take()
+
+	switch t.kind {
+
+	case tokenInt, tokenFloat, tokenImag, tokenString:
+		return literal{at: t.at, value: t.value}, nil
+
+	case tokenTrue, tokenFalse:
+		return literal{at: t.at, value: t.kind == tokenTrue}, nil
+
+	case tokenLParen:
+		inner, err := p.expression()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+
+		return inner, nil
+
+	case tokenIdent:
+		if p.peek().kind != tokenLParen {
+			return variable{at: t.at, name: t.text}, nil
+		}
+
+		return p.call(t)
+
+	default:
+		return nil, errorAt(t.at, "unexpected %s", describeToken(t))
+	}
+}
+
+// Parse the arguments of a call to the function named by the given token,
+// starting with the opening parenthesis.
+func (p *parser) call(name token) (syntax, error) {
+
+	p.take()
+	c := call{at: name.at, name: name.text}
+
+	if p.peek().kind == tokenRParen {
+		p.take()
+		return c, nil
+	}
+
+	for {
+
+		arg, err := p.expression()
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.args = append(c.args, arg)
+
+		t := p.take()
+
+		switch t.kind {
+		case tokenRParen:
+			return c, nil
+		case tokenComma:
+			continue
+		default:
+			return nil, errorAt(t.at, "expected , or ), found %s", describeToken(t))
+		}
+	}
+}
+
+// Return true if and only if kind is a comparison operator.
+func isComparison(kind tokenKind) bool {
+	return slices.Contains(precedence[2], kind) || slices.Contains(precedence[3], kind)
+}
+
+// Return a description of t for use in error messages.
+func describeToken(t token) string {
+
+	switch t.kind {
+	case tokenEOF:
+		return t.kind.String()
+	case tokenIdent, tokenInt, tokenFloat, tokenImag, tokenString:
+		return t.kind.String() + " " + t.text
+	default:
+		return "\"" + t.text + "\""
+	}
+}
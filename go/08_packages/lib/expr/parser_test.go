@@ -0,0 +1,94 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Return a fully parenthesized rendering of a syntax tree.
+func render(tree syntax) string {
+
+	switch n := tree.(type) {
+
+	case literal:
+		return fmt.Sprint(n.value)
+
+	case variable:
+		return n.name
+
+	case unary:
+		return fmt.Sprintf("(%v%s)", n.op, render(n.operand))
+
+	case binary:
+		return fmt.Sprintf("(%s %v %s)", render(n.left), n.op, render(n.right))
+
+	case conditional:
+		return fmt.Sprintf("(if %s then %s else %s)", render(n.condition), render(n.then), render(n.otherwise))
+
+	default:
+		c := n.(call)
+		args := make([]string, len(c.args))
+
+		for i, arg := range c.args {
+			args[i] = render(arg)
+		}
+
+		return fmt.Sprintf("%s(%s)", c.name, strings.Join(args, ", "))
+	}
+}
+
+func TestParse(t *testing.T) {
+
+	for source, expected := range map[string]string{
+		"x * 2 + 1":                        "((x * 2) + 1)",
+		"1 + 2 * 3 - 4":                    "((1 + (2 * 3)) - 4)",
+		"(1 + 2) * 3":                      "((1 + 2) * 3)",
+		"-x * -2":                          "((-x) * (-2))",
+		"!a || b && c == d":                "((!a) || (b && (c == d)))",
+		"x < 1 == y >= 2":                  "((x < 1) == (y >= 2))",
+		"if x > 10 then x - 10 else x":     "(if (x > 10) then (x - 10) else x)",
+		"1 + if c then 2 else 3 + 4":       "(1 + (if c then 2 else (3 + 4)))",
+		"upper(x) + f() + g(1, h(x), 2.5)": "((upper(x) + f()) + g(1, h(x), 2.5))",
+	} {
+
+		tree, err := parse(source)
+
+		if err != nil {
+			t.Errorf("%q: %v", source, err)
+			continue
+		}
+
+		if rendered := render(tree); rendered != expected {
+			t.Errorf("%q: expected %s, got %s", source, expected, rendered)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	for source, expected := range map[string]Error{
+		"":             {1, 1, "unexpected end of expression"},
+		"x +":          {1, 4, "unexpected end of expression"},
+		"x y":          {1, 3, "unexpected identifier y after expression"},
+		"(x":           {1, 3, "expected ), found end of expression"},
+		"f(x y)":       {1, 5, "expected , or ), found identifier y"},
+		"if x then 1":  {1, 12, "expected else, found end of expression"},
+		"if x\nelse 1": {2, 1, `expected then, found "else"`},
+		"1 < x < 3":    {1, 7, "comparisons cannot be chained"},
+		"a == b != c":  {1, 8, "comparisons cannot be chained"},
+		"x * )":        {1, 5, `unexpected ")"`},
+	} {
+
+		_, err := parse(source)
+
+		var e *Error
+
+		if !errors.As(err, &e) || *e != expected {
+			t.Errorf("%q: expected %v, got %v", source, &expected, err)
+		}
+	}
+}
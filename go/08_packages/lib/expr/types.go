@@ -0,0 +1,120 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"fmt"
+)
+
+// The type of an expression.
+//
+// Like MyEnum in ../../../09_enums/enums.go, Type is an int with named values
+// that implements fmt.Stringer. It also implements encoding.TextMarshaler and
+// encoding.TextUnmarshaler so that types can be named in JSON configuration,
+// e.g. "int".
+type Type int
+
+const (
+	Int Type = iota
+	Float
+	Complex
+	String
+	Bool
+)
+
+// The Go types by which values of each Type are represented.
+type value interface {
+	int | float64 | complex128 | string | bool
+}
+
+// The Go types of the numeric Types, corresponding to the Integer, Float and
+// Complex constraints in ../../../05_generics/generics.go.
+type number interface {
+	int | float64 | complex128
+}
+
+// The Go types of the Types whose values are ordered.
+type ordered interface {
+	int | float64 | string
+}
+
+// Names of the Types, indexed by Type.
+var typeNames = []string{"int", "float", "complex", "string", "bool"}
+
+// Implement fmt.Stringer.
+func (t Type) String() string {
+
+	if !t.valid() {
+		return fmt.Sprintf("<Type %d>", int(t))
+	}
+
+	return typeNames[t]
+}
+
+// Report whether t is one of the Types declared above.
+func (t Type) valid() bool {
+	return t >= 0 && int(t) < len(typeNames)
+}
+
+// Return the Type with the given name, as returned by Type.String().
+func ParseType(name string) (Type, error) {
+
+	for i, typeName := range typeNames {
+		if typeName == name {
+			return Type(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown type %q", name)
+}
+
+// Implement encoding.TextMarshaler.
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Implement encoding.TextUnmarshaler.
+func (t *Type) UnmarshalText(text []byte) error {
+
+	parsed, err := ParseType(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	*t = parsed
+	return nil
+}
+
+// Return true if and only if t is Int, Float or Complex.
+func (t Type) numeric() bool {
+	return t == Int || t == Float || t == Complex
+}
+
+// Return true if and only if a value of type t can be used where one of type
+// to is expected: every Type can be used as itself, and a number can be
+// promoted to a wider one, i.e. int to float or complex and float to complex,
+// just as every integer is also a real number and every real number is also a
+// complex one.
+func (t Type) promotes(to Type) bool {
+	return t == to || t.numeric() && to.numeric() && t < to
+}
+
+// Return the Type of the Go type T.
+func typeOf[T value]() Type {
+
+	var zero T
+
+	switch any(zero).(type) {
+	case int:
+		return Int
+	case float64:
+		return Float
+	case complex128:
+		return Complex
+	case string:
+		return String
+	default:
+		return Bool
+	}
+}
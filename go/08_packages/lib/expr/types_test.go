@@ -0,0 +1,59 @@
+// Copyright Kirk Rader 2024
+
+package expr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestType(t *testing.T) {
+
+	for _, typ := range []Type{Int, Float, Complex, String, Bool} {
+
+		parsed, err := ParseType(typ.String())
+
+		if err != nil || parsed != typ {
+			t.Errorf("%v: expected to parse its own name, got %v and %v", typ, parsed, err)
+		}
+	}
+
+	if _, err := ParseType("integer"); err == nil {
+		t.Errorf("expected an error for an unknown type")
+	}
+
+	if s := Type(9).String(); s != "<Type 9>" {
+		t.Errorf("expected <Type 9>, got %s", s)
+	}
+
+	var params struct{ Input Type }
+
+	if err := json.Unmarshal([]byte(`{"input": "float"}`), &params); err != nil || params.Input != Float {
+		t.Errorf("expected float and no error, got %v and %v", params.Input, err)
+	}
+
+	if data, err := json.Marshal(params); err != nil || string(data) != `{"Input":"float"}` {
+		t.Errorf("expected {\"Input\":\"float\"} and no error, got %s and %v", data, err)
+	}
+}
+
+func TestPromotes(t *testing.T) {
+
+	for _, test := range []struct {
+		from, to Type
+		expected bool
+	}{
+		{Int, Int, true},
+		{Int, Float, true},
+		{Int, Complex, true},
+		{Float, Complex, true},
+		{Float, Int, false},
+		{Complex, Float, false},
+		{Int, String, false},
+		{Bool, Bool, true},
+	} {
+		if test.from.promotes(test.to) != test.expected {
+			t.Errorf("%v to %v: expected %v", test.from, test.to, test.expected)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright Kirk Rader 2024
+
+// Package libtest provides property-based checks, driven by testing/quick,
+// that Transformers and the combinators built from them obey the laws on which
+// Compose() relies, for use in the tests of packages that define their own
+// Transformers.
+//
+// Each check builds random chains from the Factories it is given and applies
+// them to random values, so the Transformers that the Factories create must be
+// pure: they must always return the same result, or fail in the same way, for
+// the same argument. Chains that fail, e.g. because a Transformer is passed a
+// value of a type it does not accept, are expected and are checked along with
+// those that succeed.
+package libtest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+// Return a new Transformer, using r for any parameters, e.g. the amount by
+// which it increments its argument.
+type Factory func(r *rand.Rand) lib.Transformer
+
+// Configures the checks in this package.
+type Config struct {
+
+	// Create the Transformers from which random chains are built. At least
+	// one is required.
+	Factories []Factory
+
+	// Return a random initial value, or nil for RandomValue().
+	Values func(r *rand.Rand) any
+
+	// Maximum number of Transformers in a random chain; values less than 1
+	// are treated as 5.
+	MaxLength int
+
+	// Report whether two results are equal, or nil for reflect.DeepEqual().
+	Equal func(a, b any) bool
+
+	// Passed to quick.Check(), e.g. to set MaxCount; may be nil.
+	Quick *quick.Config
+}
+
+// Return a random value of one of several predeclared types, or a slice or map
+// of them, or nil.
+func RandomValue(r *rand.Rand) any {
+
+	switch r.Intn(8) {
+	case 0:
+		return nil
+	case 1:
+		return r.Intn(200) - 100
+	case 2:
+		return r.NormFloat64() * 100
+	case 3:
+		return fmt.Sprint(r.Intn(1000))
+	case 4:
+		return r.Intn(2) == 0
+	case 5:
+		return complex(r.NormFloat64(), r.NormFloat64())
+	case 6:
+		values := make([]int, r.Intn(4))
+		for i := range values {
+			values[i] = r.Intn(10)
+		}
+		return values
+	default:
+		return map[string]int{"n": r.Intn(10)}
+	}
+}
+
+// Return a chain of between 0 and maxLength Transformers, each created by a
+// randomly chosen one of the given Factories.
+func RandomChain(r *rand.Rand, factories []Factory, maxLength int) []lib.Transformer {
+
+	chain := make([]lib.Transformer, r.Intn(maxLength+1))
+
+	for i := range chain {
+		chain[i] = factories[r.Intn(len(factories))](r)
+	}
+
+	return chain
+}
+
+// Check that inserting the given identity Transformer at any position in a
+// chain changes neither its result nor, apart from the shift in the indices of
+// later steps, the step at which it fails, if it does.
+//
+// If identity is nil, lib.Identity is used.
+func CheckIdentity(t testing.TB, identity lib.Transformer, config Config) {
+
+	t.Helper()
+
+	if identity == nil {
+		identity = lib.Identity
+	}
+
+	check(t, "identity", config, func(r *rand.Rand) error {
+
+		value, chain := config.value(r), config.chain(r)
+		position := r.Intn(len(chain) + 1)
+		padded := append(append(append([]lib.Transformer{}, chain[:position]...), identity), chain[position:]...)
+
+		expected, expectedErr := lib.Compose(value, chain...)
+		actual, actualErr := lib.Compose(value, padded...)
+
+		if failed, err := bothFail(expectedErr, actualErr); failed || err != nil {
+
+			if err != nil {
+				return fmt.Errorf("with identity at %d: %w", position, err)
+			}
+
+			step := failedStep(expectedErr)
+
+			if step >= position {
+				step += 1
+			}
+
+			if failedStep(actualErr) != step {
+				return fmt.Errorf(
+					"with identity at %d: expected failure at step %d, got %v",
+					position,
+					step,
+					actualErr)
+			}
+
+			return nil
+		}
+
+		if !config.equal(expected, actual) {
+			return fmt.Errorf("with identity at %d: expected %v, got %v", position, expected, actual)
+		}
+
+		return nil
+	})
+}
+
+// Check that composition is associative, i.e. that splitting a chain at any
+// point and passing the result of the first part to the second gives the same
+// result as the whole chain, e.g. Compose(Compose(x, a, b), c) is the same as
+// Compose(x, a, b, c), and fails at the same step if the whole chain does.
+func CheckAssociativity(t testing.TB, config Config) {
+
+	t.Helper()
+
+	check(t, "associativity", config, func(r *rand.Rand) error {
+
+		value, chain := config.value(r), config.chain(r)
+		split := r.Intn(len(chain) + 1)
+
+		expected, expectedErr := lib.Compose(value, chain...)
+		mid, midErr := lib.Compose(value, chain[:split]...)
+
+		if midErr != nil {
+
+			if expectedErr == nil || failedStep(expectedErr) != failedStep(midErr) {
+				return fmt.Errorf("split at %d: first part failed with %v but whole chain with %v", split, midErr, expectedErr)
+			}
+
+			return nil
+		}
+
+		actual, actualErr := lib.Compose(mid, chain[split:]...)
+
+		if failed, err := bothFail(expectedErr, actualErr); failed || err != nil {
+
+			if err != nil {
+				return fmt.Errorf("split at %d: %w", split, err)
+			}
+
+			if failedStep(actualErr)+split != failedStep(expectedErr) {
+				return fmt.Errorf("split at %d: second part failed with %v but whole chain with %v", split, actualErr, expectedErr)
+			}
+
+			return nil
+		}
+
+		if !config.equal(expected, actual) {
+			return fmt.Errorf("split at %d: expected %v, got %v", split, expected, actual)
+		}
+
+		return nil
+	})
+}
+
+// Check that a panic at any position in a chain stops it: the pipeline fails
+// with a *lib.ComposeError for the step that panicked, or an earlier one, and
+// no later step is invoked.
+func CheckPanicPropagation(t testing.TB, config Config) {
+
+	t.Helper()
+
+	sentinel := errors.New("libtest: deliberate panic")
+
+	check(t, "panic propagation", config, func(r *rand.Rand) error {
+
+		value, chain := config.value(r), config.chain(r)
+		position := r.Intn(len(chain) + 1)
+
+		chain = append(append(append([]lib.Transformer{}, chain[:position]...), func(any) any {
+			panic(sentinel)
+		}), chain[position:]...)
+
+		// Count the invocations of each step.
+		calls := make([]int, len(chain))
+		counted := make([]lib.Transformer, len(chain))
+
+		for i, transformer := range chain {
+			counted[i] = func(value any) any {
+				calls[i] += 1
+				return transformer(value)
+			}
+		}
+
+		_, err := lib.Compose(value, counted...)
+
+		var composeError *lib.ComposeError
+
+		if !errors.As(err, &composeError) {
+			return fmt.Errorf("panic at %d: expected a *lib.ComposeError, got %v", position, err)
+		}
+
+		if composeError.Step > position {
+			return fmt.Errorf("panic at %d: reported for step %d", position, composeError.Step)
+		}
+
+		if composeError.Step == position && composeError.Recovered != sentinel {
+			return fmt.Errorf("panic at %d: expected %v to have been recovered, got %v", position, sentinel, composeError.Recovered)
+		}
+
+		for i := composeError.Step + 1; i < len(calls); i++ {
+			if calls[i] != 0 {
+				return fmt.Errorf("panic at %d: step %d was invoked after step %d failed", position, i, composeError.Step)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Run all of the checks in this package.
+func CheckAll(t testing.TB, identity lib.Transformer, config Config) {
+
+	t.Helper()
+	CheckIdentity(t, identity, config)
+	CheckAssociativity(t, config)
+	CheckPanicPropagation(t, config)
+}
+
+// Report the property as failed if it does not hold for random seeds.
+func check(t testing.TB, name string, config Config, property func(r *rand.Rand) error) {
+
+	t.Helper()
+
+	if len(config.Factories) == 0 {
+		t.Fatalf("libtest: %s: no Factories", name)
+	}
+
+	var failure error
+
+	err := quick.Check(func(seed int64) bool {
+		failure = property(rand.New(rand.NewSource(seed)))
+		return failure == nil
+	}, config.Quick)
+
+	if err != nil {
+		t.Errorf("%s: %v: %v", name, err, failure)
+	}
+}
+
+// Return a random initial value.
+func (c Config) value(r *rand.Rand) any {
+
+	if c.Values == nil {
+		return RandomValue(r)
+	}
+
+	return c.Values(r)
+}
+
+// Return a random chain.
+func (c Config) chain(r *rand.Rand) []lib.Transformer {
+
+	maxLength := c.MaxLength
+
+	if maxLength < 1 {
+		maxLength = 5
+	}
+
+	return RandomChain(r, c.Factories, maxLength)
+}
+
+// Report whether a and b are equal.
+func (c Config) equal(a, b any) bool {
+
+	if c.Equal == nil {
+		return reflect.DeepEqual(a, b)
+	}
+
+	return c.Equal(a, b)
+}
+
+// Return true if both errors are non-nil, false if both are nil, or an error if
+// only one of them is.
+func bothFail(expected, actual error) (bool, error) {
+
+	switch {
+	case expected != nil && actual != nil:
+		return true, nil
+	case expected == nil && actual == nil:
+		return false, nil
+	case expected != nil:
+		return false, fmt.Errorf("expected failure %v, got success", expected)
+	default:
+		return false, fmt.Errorf("expected success, got failure %v", actual)
+	}
+}
+
+// Return the index of the step at which a chain failed with the given error,
+// or -1 if it is not a *lib.ComposeError.
+func failedStep(err error) int {
+
+	var composeError *lib.ComposeError
+
+	if errors.As(err, &composeError) {
+		return composeError.Step
+	}
+
+	return -1
+}
@@ -0,0 +1,120 @@
+// Copyright Kirk Rader 2024
+
+package libtest
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	"parasaurolophus/tutorial/08_packages/lib"
+)
+
+// Factories for a mixture of Transformers, some of which fail for some values.
+var factories = []Factory{
+	func(r *rand.Rand) lib.Transformer {
+		n := r.Intn(10)
+		return lib.MakeTransformer(func(value int) int { return value + n })
+	},
+	func(r *rand.Rand) lib.Transformer {
+		n := r.Intn(5) - 2
+		return lib.MakeTransformer(func(value int) int { return value * n })
+	},
+	func(r *rand.Rand) lib.Transformer {
+		return lib.MakeTransformer(func(value int) int { return 100 / value })
+	},
+	func(r *rand.Rand) lib.Transformer {
+		return lib.MakeMapper(strconv.Itoa)
+	},
+	func(r *rand.Rand) lib.Transformer {
+		return func(value any) any {
+
+			n, err := strconv.Atoi(value.(string))
+
+			if err != nil {
+				panic(err)
+			}
+
+			return n
+		}
+	},
+	func(r *rand.Rand) lib.Transformer {
+		return lib.If(
+			func(value any) bool { _, ok := value.(int); return ok },
+			lib.MakeTransformer(func(value int) int { return -value }),
+			nil)
+	},
+}
+
+// Records failures instead of reporting them, so that tests can check that the
+// checks detect violations.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestCheckAll(t *testing.T) {
+
+	CheckAll(t, nil, Config{Factories: factories})
+
+	CheckAll(t, nil, Config{
+		Factories: factories,
+		Values:    func(r *rand.Rand) any { return r.Intn(100) },
+		MaxLength: 8,
+		Quick:     &quick.Config{MaxCount: 200},
+	})
+}
+
+func TestCheckIdentityDetectsViolations(t *testing.T) {
+
+	notIdentity := lib.MakeTransformer(func(value int) int { return value + 1 })
+	r := &recorder{TB: t}
+
+	CheckIdentity(r, notIdentity, Config{
+		Factories: factories,
+		Values:    func(r *rand.Rand) any { return r.Intn(100) },
+	})
+
+	if len(r.failures) != 1 {
+		t.Errorf("expected 1 failure, got %v", r.failures)
+	}
+}
+
+func TestCheckAssociativityDetectsViolations(t *testing.T) {
+
+	// Not pure, so the same chain gives different results when run twice.
+	count := 0
+
+	impure := func(r *rand.Rand) lib.Transformer {
+		return func(value any) any {
+			count += 1
+			return count
+		}
+	}
+
+	r := &recorder{TB: t}
+	CheckAssociativity(r, Config{Factories: []Factory{impure}})
+
+	if len(r.failures) != 1 {
+		t.Errorf("expected 1 failure, got %v", r.failures)
+	}
+}
+
+func TestRandomChain(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		if chain := RandomChain(r, factories, 3); len(chain) > 3 {
+			t.Fatalf("expected at most 3 Transformers, got %d", len(chain))
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Configures a Memo created by Memoize().
+//
+// The zero value caches every comparable argument forever.
+type MemoOptions struct {
+
+	// Maximum number of results to cache, or zero for no limit. When the cache
+	// is full, the least recently used result is evicted to make room.
+	Size int
+
+	// How long a cached result remains valid, or zero for forever. Expired
+	// results are removed when looked up and, starting with the least recently
+	// used, whenever a result is cached, so that even with no Size limit the
+	// cache holds only results used within the last TTL.
+	TTL time.Duration
+
+	// Return the cache key for an argument and true, or false if the result
+	// for that argument should not be cached. The key must be comparable. If
+	// Key is nil, the argument itself is the key when it is comparable and
+	// arguments that are not, e.g. slices and maps, are not cached. Either way,
+	// a key that is not equal to itself, e.g. math.NaN(), is not cached.
+	Key func(value any) (key any, ok bool)
+
+	// Clock used to expire results, or nil for SystemClock.
+	Clock Clock
+}
+
+// Counts of what a Memo has done since it was created.
+type MemoStats struct {
+
+	// Number of times a cached result was returned.
+	Hits uint64
+
+	// Number of times the wrapped Transformer was invoked because no valid
+	// result was cached, including for arguments that are not cached at all.
+	Misses uint64
+
+	// Number of results removed to make room for newer ones.
+	Evictions uint64
+
+	// Number of results removed because they had outlived the TTL.
+	Expirations uint64
+
+	// Number of arguments that could not be cached, e.g. because they were not
+	// comparable or were NaN.
+	Uncacheable uint64
+}
+
+// Caches the results of a Transformer. Create using Memoize().
+//
+// A Memo is safe for concurrent use. Concurrent calls with the same uncached
+// argument may each invoke the wrapped Transformer.
+type Memo struct {
+	transformer Transformer
+	options     MemoOptions
+	mutex       sync.Mutex
+	entries     map[any]*list.Element
+	order       *list.List
+	stats       MemoStats
+}
+
+// A cached result, stored in Memo.order from most to least recently used.
+type memoEntry struct {
+	key     any
+	value   any
+	expires time.Time
+}
+
+// Return a Memo that caches the results of the given Transformer, which should
+// be pure, i.e. always return the same result for the same argument and have
+// no side effects, according to the given options.
+//
+// Only successful results are cached. When the wrapped Transformer panics or
+// returns an error, the Memo's Transformer fails in the same way and the next
+// call with the same argument invokes the wrapped Transformer again.
+func Memoize(transformer Transformer, options MemoOptions) *Memo {
+
+	if options.Clock == nil {
+		options.Clock = SystemClock
+	}
+
+	return &Memo{
+		transformer: transformer,
+		options:     options,
+		entries:     map[any]*list.Element{},
+		order:       list.New(),
+	}
+}
+
+// Return a Transformer that returns cached results where possible and
+// otherwise invokes the wrapped one.
+//
+// The returned Transformer accepts and returns the same types as the one it
+// wraps, as far as Validate() is concerned.
+func (m *Memo) Transformer() Transformer {
+
+	s := &stage{
+		run:  m.run,
+		name: "Memoize",
+		children: func() []Description {
+			return []Description{describe("", m.transformer)}
+		},
+	}
+
+	if inner := inspect(m.transformer); inner != nil {
+		s.in, s.out = inner.in, inner.out
+	}
+
+	return s.transformer()
+}
+
+// Return a snapshot of m's statistics.
+func (m *Memo) Stats() MemoStats {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.stats
+}
+
+// Discard all cached results.
+func (m *Memo) Clear() {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries = map[any]*list.Element{}
+	m.order.Init()
+}
+
+// Implement the stage returned by Transformer().
+func (m *Memo) run(ctx context.Context, value any) (any, error) {
+
+	key, cacheable := m.key(value)
+
+	if cacheable {
+		if result, ok := m.lookup(key); ok {
+			return result, nil
+		}
+	}
+
+	// A panic propagates from here, so it is never cached.
+	result, err := call(ctx, m.transformer, value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheable {
+		m.store(key, result)
+	}
+
+	return result, nil
+}
+
+// Return the cache key for the given argument, and whether it can be cached.
+func (m *Memo) key(value any) (any, bool) {
+
+	key, ok := value, value == nil || reflect.ValueOf(value).Comparable()
+
+	if m.options.Key != nil {
+		key, ok = m.options.Key(value)
+	}
+
+	// A key that is not equal to itself, e.g. NaN or a struct containing one,
+	// can be stored in a map but never found there again, nor deleted.
+	ok = ok && key == key
+
+	if !ok {
+		m.mutex.Lock()
+		m.stats.Uncacheable += 1
+		m.stats.Misses += 1
+		m.mutex.Unlock()
+	}
+
+	return key, ok
+}
+
+// Return the cached result for the given key, if any, updating statistics and
+// recency.
+func (m *Memo) lookup(key any) (any, bool) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	element, ok := m.entries[key]
+
+	if ok {
+
+		entry := element.Value.(*memoEntry)
+
+		if m.options.TTL <= 0 || m.options.Clock.Now().Before(entry.expires) {
+			m.stats.Hits += 1
+			m.order.MoveToFront(element)
+			return entry.value, true
+		}
+
+		m.order.Remove(element)
+		delete(m.entries, key)
+		m.stats.Expirations += 1
+	}
+
+	m.stats.Misses += 1
+	return nil, false
+}
+
+// Cache the given result, removing expired results and evicting the least
+// recently used one if necessary.
+func (m *Memo) store(key any, value any) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry := &memoEntry{key: key, value: value}
+
+	if m.options.TTL > 0 {
+		now := m.options.Clock.Now()
+		entry.expires = now.Add(m.options.TTL)
+		m.expire(now)
+	}
+
+	// Another goroutine may have cached a result for the same key while this
+	// one was invoking the wrapped Transformer.
+	if element, ok := m.entries[key]; ok {
+		element.Value = entry
+		m.order.MoveToFront(element)
+		return
+	}
+
+	m.entries[key] = m.order.PushFront(entry)
+
+	if m.options.Size > 0 && m.order.Len() > m.options.Size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoEntry).key)
+		m.stats.Evictions += 1
+	}
+}
+
+// Remove expired results, starting with the least recently used and stopping
+// at the first that has not expired. That one was cached within the last TTL
+// and those used more recently were used no earlier than it was cached, so all
+// that remain were used within the last TTL, even if some of them have expired.
+func (m *Memo) expire(now time.Time) {
+
+	for oldest := m.order.Back(); oldest != nil; oldest = m.order.Back() {
+
+		entry := oldest.Value.(*memoEntry)
+
+		if now.Before(entry.expires) {
+			return
+		}
+
+		m.order.Remove(oldest)
+		delete(m.entries, entry.key)
+		m.stats.Expirations += 1
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Return a Transformer that squares its argument, and a pointer to the number
+// of times it has been called.
+func counted() (Transformer, *int) {
+
+	calls := 0
+	mutex := &sync.Mutex{}
+
+	return MakeTransformer(func(value int) int {
+
+		mutex.Lock()
+		calls += 1
+		mutex.Unlock()
+
+		if value < 0 {
+			panic("negative")
+		}
+
+		return value * value
+	}), &calls
+}
+
+func TestMemoize(t *testing.T) {
+
+	square, calls := counted()
+	memo := Memoize(square, MemoOptions{})
+	transformer := memo.Transformer()
+
+	for _, input := range []int{2, 3, 2, 2, 3} {
+
+		result, err := Compose(input, transformer)
+
+		if err != nil || result != input*input {
+			t.Errorf("%d: expected %d and no error, got %v and %v", input, input*input, result, err)
+		}
+	}
+
+	if *calls != 2 {
+		t.Errorf("expected 2 calls, got %d", *calls)
+	}
+
+	stats := memo.Stats()
+
+	if stats.Hits != 3 || stats.Misses != 2 {
+		t.Errorf("expected 3 hits and 2 misses, got %+v", stats)
+	}
+
+	memo.Clear()
+	Compose(2, transformer)
+
+	if *calls != 3 {
+		t.Errorf("expected Clear() to discard results, got %d calls", *calls)
+	}
+}
+
+func TestMemoizePanic(t *testing.T) {
+
+	square, calls := counted()
+	transformer := Memoize(square, MemoOptions{}).Transformer()
+
+	for i := 0; i < 2; i++ {
+		if _, err := Compose(-1, transformer); err == nil {
+			t.Errorf("expected an error")
+		}
+	}
+
+	if *calls != 2 {
+		t.Errorf("expected the panic not to have been cached, got %d calls", *calls)
+	}
+}
+
+func TestMemoizeLRU(t *testing.T) {
+
+	square, calls := counted()
+	memo := Memoize(square, MemoOptions{Size: 2})
+	transformer := memo.Transformer()
+
+	// 1 is evicted when 3 is added, since 2 was used more recently.
+	for _, input := range []int{1, 2, 2, 3, 2, 1} {
+		Compose(input, transformer)
+	}
+
+	if *calls != 4 {
+		t.Errorf("expected 4 calls, got %d", *calls)
+	}
+
+	stats := memo.Stats()
+
+	if stats.Evictions != 2 || stats.Hits != 2 || stats.Misses != 4 {
+		t.Errorf("expected 2 evictions, 2 hits and 4 misses, got %+v", stats)
+	}
+}
+
+func TestMemoizeTTL(t *testing.T) {
+
+	clock := newFakeClock()
+	square, calls := counted()
+	memo := Memoize(square, MemoOptions{TTL: time.Minute, Clock: clock})
+	transformer := memo.Transformer()
+
+	Compose(2, transformer)
+	clock.Advance(59 * time.Second)
+	Compose(2, transformer)
+	clock.Advance(time.Second)
+	Compose(2, transformer)
+
+	if *calls != 2 {
+		t.Errorf("expected 2 calls, got %d", *calls)
+	}
+
+	if stats := memo.Stats(); stats.Expirations != 1 || stats.Hits != 1 {
+		t.Errorf("expected 1 expiration and 1 hit, got %+v", stats)
+	}
+}
+
+func TestMemoizeTTLBoundsSize(t *testing.T) {
+
+	clock := newFakeClock()
+	square, _ := counted()
+	memo := Memoize(square, MemoOptions{TTL: time.Minute, Clock: clock})
+	transformer := memo.Transformer()
+
+	for i := 0; i < 100; i++ {
+		Compose(i, transformer)
+	}
+
+	clock.Advance(time.Minute)
+	Compose(100, transformer)
+
+	if n := len(memo.entries); n != 1 {
+		t.Errorf("expected only the latest result to remain cached, got %d", n)
+	}
+
+	if stats := memo.Stats(); stats.Expirations != 100 {
+		t.Errorf("expected 100 expirations, got %+v", stats)
+	}
+}
+
+func TestMemoizeNaN(t *testing.T) {
+
+	half := MakeTransformer(func(value float64) float64 { return value / 2 })
+	type point struct{ x, y float64 }
+	origin := MakeTransformer(func(value point) point { return point{} })
+
+	memo := Memoize(half, MemoOptions{Size: 2})
+	points := Memoize(origin, MemoOptions{Size: 2})
+
+	for i := 0; i < 100; i++ {
+		Compose(math.NaN(), memo.Transformer())
+		Compose(point{math.NaN(), 0}, points.Transformer())
+	}
+
+	for _, m := range []*Memo{memo, points} {
+
+		if n, stats := len(m.entries), m.Stats(); n != 0 || stats.Uncacheable != 100 || stats.Misses != 100 {
+			t.Errorf("expected nothing cached and 100 uncacheable misses, got %d and %+v", n, stats)
+		}
+	}
+}
+
+func TestMemoizeKey(t *testing.T) {
+
+	calls := 0
+
+	sum := MakeTransformer(func(values []int) []int {
+
+		calls += 1
+		total := 0
+
+		for _, value := range values {
+			total += value
+		}
+
+		return []int{total}
+	})
+
+	// Slices are not comparable, so are not cached by default.
+	plain := Memoize(sum, MemoOptions{})
+	Compose([]int{1, 2}, plain.Transformer())
+	Compose([]int{1, 2}, plain.Transformer())
+
+	if calls != 2 || plain.Stats().Uncacheable != 2 {
+		t.Errorf("expected 2 uncacheable calls, got %d and %+v", calls, plain.Stats())
+	}
+
+	keyed := Memoize(sum, MemoOptions{
+		Key: func(value any) (any, bool) { return fmt.Sprint(value), true },
+	})
+
+	calls = 0
+	Compose([]int{1, 2}, keyed.Transformer())
+	result, _ := Compose([]int{1, 2}, keyed.Transformer())
+
+	if calls != 1 || result.([]int)[0] != 3 {
+		t.Errorf("expected 1 call and [3], got %d and %v", calls, result)
+	}
+}
+
+func TestMemoizeConcurrent(t *testing.T) {
+
+	square, _ := counted()
+	memo := Memoize(square, MemoOptions{Size: 8})
+	transformer := memo.Transformer()
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 8; i++ {
+
+		wg.Add(1)
+
+		go func(i int) {
+
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+
+				input := (i + j) % 16
+
+				if result, err := Compose(input, transformer); err != nil || result != input*input {
+					t.Errorf("%d: expected %d and no error, got %v and %v", input, input*input, result, err)
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if stats := memo.Stats(); stats.Hits+stats.Misses != 800 {
+		t.Errorf("expected 800 lookups, got %+v", stats)
+	}
+}
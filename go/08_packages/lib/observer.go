@@ -0,0 +1,197 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"runtime/pprof"
+	"strconv"
+	"time"
+)
+
+// Describes a single step of a pipeline to an Observer.
+type StepEvent struct {
+
+	// The context passed to ComposeContext() or ComposeStream(), or
+	// context.Background() for Compose().
+	Context context.Context
+
+	// Zero-based index of the Transformer.
+	Step int
+
+	// The value passed to the Transformer.
+	Input any
+
+	// The value returned by the Transformer; always nil for BeforeStep() and
+	// OnPanic().
+	Output any
+
+	// How long the Transformer took; always zero for BeforeStep().
+	Duration time.Duration
+
+	// The *ComposeError describing how the Transformer failed, if it did;
+	// always nil for BeforeStep().
+	Err error
+}
+
+// Notified by a Composer created with the Observe() Option before and after
+// each step it runs.
+//
+// Methods may be called concurrently by ComposeStream() and by concurrent calls
+// to Compose() etc. using the same Composer, so implementations must be safe
+// for concurrent use.
+type Observer interface {
+
+	// Called before a Transformer is invoked.
+	BeforeStep(event StepEvent)
+
+	// Called after a Transformer returns or fails without panicking.
+	AfterStep(event StepEvent)
+
+	// Called instead of AfterStep() after a Transformer panics, with the value
+	// passed to panic().
+	OnPanic(event StepEvent, recovered any)
+}
+
+// Cause the Composer to notify the given Observers before and after each step.
+//
+// BeforeStep() is called on each Observer in the order given; AfterStep() and
+// OnPanic() are called in the reverse order, so that Observers nest like
+// deferred function calls.
+func Observe(observers ...Observer) Option {
+
+	return func(c *Composer) {
+		c.observers = append(c.observers, observers...)
+	}
+}
+
+// Observer that writes each step to a log/slog Logger: BeforeStep() at
+// slog.LevelDebug, AfterStep() at slog.LevelInfo or at slog.LevelWarn if the
+// step failed, and OnPanic() at slog.LevelError.
+type SlogObserver struct {
+
+	// The Logger to which to write, or nil to use slog.Default().
+	Logger *slog.Logger
+}
+
+// Return the Logger to which o writes.
+func (o SlogObserver) logger() *slog.Logger {
+
+	if o.Logger == nil {
+		return slog.Default()
+	}
+
+	return o.Logger
+}
+
+// Implement Observer.BeforeStep().
+func (o SlogObserver) BeforeStep(event StepEvent) {
+
+	o.logger().DebugContext(
+		event.Context,
+		"starting step",
+		slog.Int("step", event.Step),
+		slog.Any("input", event.Input))
+}
+
+// Implement Observer.AfterStep().
+func (o SlogObserver) AfterStep(event StepEvent) {
+
+	if event.Err != nil {
+		o.logger().WarnContext(
+			event.Context,
+			"step failed",
+			slog.Int("step", event.Step),
+			slog.Any("input", event.Input),
+			slog.Duration("duration", event.Duration),
+			slog.Any("error", event.Err))
+		return
+	}
+
+	o.logger().InfoContext(
+		event.Context,
+		"finished step",
+		slog.Int("step", event.Step),
+		slog.Any("input", event.Input),
+		slog.Any("output", event.Output),
+		slog.Duration("duration", event.Duration))
+}
+
+// Implement Observer.OnPanic().
+func (o SlogObserver) OnPanic(event StepEvent, recovered any) {
+
+	o.logger().ErrorContext(
+		event.Context,
+		"step panicked",
+		slog.Int("step", event.Step),
+		slog.Any("input", event.Input),
+		slog.Duration("duration", event.Duration),
+		slog.Any("recovered", recovered))
+}
+
+// Observer that accumulates per-step statistics in an expvar.Map, which can
+// then be published using expvar.Publish() or created using expvar.NewMap().
+//
+// For each step N, it maintains the following expvar.Int values:
+//
+//	N.calls        number of times the step was invoked
+//	N.errors       number of times it failed without panicking
+//	N.panics       number of times it panicked
+//	N.nanoseconds  total time spent in it
+type ExpvarObserver struct {
+
+	// The map in which to accumulate statistics.
+	Map *expvar.Map
+}
+
+// Implement Observer.BeforeStep().
+func (o ExpvarObserver) BeforeStep(event StepEvent) {
+	o.Map.Add(strconv.Itoa(event.Step)+".calls", 1)
+}
+
+// Implement Observer.AfterStep().
+func (o ExpvarObserver) AfterStep(event StepEvent) {
+
+	prefix := strconv.Itoa(event.Step)
+	o.Map.Add(prefix+".nanoseconds", event.Duration.Nanoseconds())
+
+	if event.Err != nil {
+		o.Map.Add(prefix+".errors", 1)
+	}
+}
+
+// Implement Observer.OnPanic().
+func (o ExpvarObserver) OnPanic(event StepEvent, _ any) {
+
+	prefix := strconv.Itoa(event.Step)
+	o.Map.Add(prefix+".nanoseconds", event.Duration.Nanoseconds())
+	o.Map.Add(prefix+".panics", 1)
+}
+
+// Observer that sets a runtime/pprof label named "step" on the current
+// goroutine for the duration of each step, so that CPU profiles attribute time
+// to individual steps.
+//
+// Labels carried by the step's Context are preserved while the step runs and
+// are what the goroutine's labels are restored to afterwards. Goroutines
+// started by a Transformer inherit the label.
+type PprofObserver struct{}
+
+// Implement Observer.BeforeStep().
+func (PprofObserver) BeforeStep(event StepEvent) {
+
+	ctx := pprof.WithLabels(event.Context, pprof.Labels("step", strconv.Itoa(event.Step)))
+	pprof.SetGoroutineLabels(ctx)
+}
+
+// Implement Observer.AfterStep().
+func (PprofObserver) AfterStep(event StepEvent) {
+	pprof.SetGoroutineLabels(event.Context)
+}
+
+// Implement Observer.OnPanic().
+func (PprofObserver) OnPanic(event StepEvent, _ any) {
+	pprof.SetGoroutineLabels(event.Context)
+}
@@ -0,0 +1,201 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Observer that records each notification as a string.
+type recordingObserver struct {
+	name   string
+	mutex  sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(format string, args ...any) {
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.events = append(o.events, o.name+" "+fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) BeforeStep(event StepEvent) {
+	o.record("before %d %v", event.Step, event.Input)
+}
+
+func (o *recordingObserver) AfterStep(event StepEvent) {
+	o.record("after %d %v %v", event.Step, event.Output, event.Err != nil)
+}
+
+func (o *recordingObserver) OnPanic(event StepEvent, recovered any) {
+	o.record("panic %d %v", event.Step, event.Input)
+}
+
+func TestObserve(t *testing.T) {
+
+	events := &recordingObserver{name: "a"}
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	sub := MakeTransformer(func(value float64) float64 { return value - 1.0 })
+
+	_, err := NewComposer(Observe(events)).Compose(0, add, sub, add)
+
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	expected := []string{
+		"a before 0 0",
+		"a after 0 1 false",
+		"a before 1 1",
+		"a panic 1 1",
+	}
+
+	if strings.Join(events.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, got %v", expected, events.events)
+	}
+}
+
+func TestObserveOrder(t *testing.T) {
+
+	events := &recordingObserver{name: "order"}
+
+	fail := MakeFallibleTransformer(func(value int) (int, error) { return 0, fmt.Errorf("failed") })
+
+	composer := NewComposer(Observe(
+		observerFunc(func(s string) { events.record("outer %s", s) }),
+		observerFunc(func(s string) { events.record("inner %s", s) })))
+
+	_, err := composer.Compose(0, fail)
+
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	expected := []string{
+		"order outer before",
+		"order inner before",
+		"order inner after true",
+		"order outer after true",
+	}
+
+	if strings.Join(events.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %q, got %q", expected, events.events)
+	}
+}
+
+// Observer that reports each notification to a function.
+type observerFunc func(string)
+
+func (f observerFunc) BeforeStep(event StepEvent) {
+	f("before")
+}
+
+func (f observerFunc) AfterStep(event StepEvent) {
+	f(fmt.Sprintf("after %v", event.Err != nil))
+}
+
+func (f observerFunc) OnPanic(event StepEvent, recovered any) {
+	f("panic")
+}
+
+func TestObserveStream(t *testing.T) {
+
+	events := &recordingObserver{name: "s"}
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	out, errs := NewComposer(Observe(events)).ComposeStream(context.Background(), produce(0, 1), add, add)
+	drain(out, errs)
+
+	if len(events.events) != 8 {
+		t.Errorf("expected 8 events, got %v", events.events)
+	}
+}
+
+func TestSlogObserver(t *testing.T) {
+
+	buffer := &bytes.Buffer{}
+	logger := slog.New(slog.NewJSONHandler(buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	sub := MakeTransformer(func(value float64) float64 { return value - 1.0 })
+
+	NewComposer(Observe(SlogObserver{Logger: logger})).Compose(0, add, sub)
+
+	levels := []string{}
+	decoder := json.NewDecoder(buffer)
+
+	for decoder.More() {
+
+		record := map[string]any{}
+
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		levels = append(levels, fmt.Sprintf("%v %v", record["level"], record["step"]))
+	}
+
+	expected := "DEBUG 0,INFO 0,DEBUG 1,ERROR 1"
+
+	if strings.Join(levels, ",") != expected {
+		t.Errorf("expected %s, got %v", expected, levels)
+	}
+}
+
+func TestExpvarObserver(t *testing.T) {
+
+	stats := new(expvar.Map).Init()
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	even := MakeFallibleTransformer(func(value int) (int, error) {
+
+		if value%2 == 1 {
+			return 0, fmt.Errorf("odd")
+		}
+
+		return value, nil
+	})
+
+	composer := NewComposer(Observe(ExpvarObserver{Map: stats}))
+
+	composer.Compose(0, add, add, even)
+	composer.Compose(0, add, even)
+
+	for key, expected := range map[string]string{
+		"0.calls":  "2",
+		"1.calls":  "2",
+		"2.calls":  "1",
+		"1.errors": "1",
+	} {
+		if value := stats.Get(key); value == nil || value.String() != expected {
+			t.Errorf("expected %s to be %s, got %v", key, expected, value)
+		}
+	}
+
+	if stats.Get("0.nanoseconds") == nil {
+		t.Errorf("expected timing to be recorded")
+	}
+}
+
+func TestPprofObserver(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	result, err := NewComposer(Observe(PprofObserver{})).Compose(0, add, add)
+
+	if err != nil || result != 2 {
+		t.Errorf("expected 2 and no error, got %v and %v", result, err)
+	}
+}
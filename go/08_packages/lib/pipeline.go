@@ -0,0 +1,249 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"strconv"
+)
+
+// A sequence of steps that accepts a value of type In and produces a value of
+// type Out.
+//
+// Unlike a slice of Transformer functions passed to Compose(), each step of a
+// Pipeline carries its input and output types, so connecting a step to one
+// whose output is of the wrong type is a compile-time rather than run-time
+// error:
+//
+//	p := lib.NewPipeline[string]()
+//	q := lib.Then(p, strconv.Atoi)                    // Pipeline[string, int]
+//	r := lib.Map(q, func(n int) float64 { return 0 }) // Pipeline[string, float64]
+//	s := lib.Map(q, strings.ToUpper)                   // does not compile
+//
+// Note that Go does not support generic methods, which is why Map(), Then()
+// and Append() are functions rather than methods of Pipeline.
+//
+// The zero value is not usable; start with NewPipeline() or FromTransformer().
+type Pipeline[In, Out any] struct {
+
+	// Number of steps in the pipeline.
+	steps int
+
+	// Run the pipeline, numbering its steps starting from offset.
+	run func(ctx context.Context, offset int, value In) (Out, error)
+
+	// Descriptions of the steps, for Describe(), without their labels.
+	described []Description
+}
+
+// Return a Pipeline with no steps, i.e. one that returns its input unchanged.
+func NewPipeline[T any]() Pipeline[T, T] {
+
+	return Pipeline[T, T]{
+		run: func(_ context.Context, _ int, value T) (T, error) { return value, nil },
+	}
+}
+
+// Return a Pipeline that applies f to the output of p.
+func Map[In, Mid, Out any](p Pipeline[In, Mid], f func(Mid) Out) Pipeline[In, Out] {
+	return Then(p, func(value Mid) (Out, error) { return f(value), nil })
+}
+
+// Return a Pipeline that applies f to the output of p, stopping with an error
+// if f returns one.
+func Then[In, Mid, Out any](p Pipeline[In, Mid], f func(Mid) (Out, error)) Pipeline[In, Out] {
+
+	q := then(p, func(_ context.Context, value Mid) (Out, error) { return f(value) })
+	q.described = append(q.described, describeFunc[Mid, Out]())
+	return q
+}
+
+// Return the Description of a step of a Pipeline that accepts In and returns
+// Out.
+func describeFunc[In, Out any]() Description {
+
+	in, out := reflect.TypeFor[In](), reflect.TypeFor[Out]()
+	return Description{Name: typeName(in, out), In: in, Out: out}
+}
+
+// Like Then() but passes the Pipeline's ctx to f. The caller must append the
+// new step's Description to the result's.
+func then[In, Mid, Out any](p Pipeline[In, Mid], f func(context.Context, Mid) (Out, error)) Pipeline[In, Out] {
+
+	step := p.steps
+
+	return Pipeline[In, Out]{
+		steps:     step + 1,
+		described: slices.Clip(p.described),
+		run: func(ctx context.Context, offset int, value In) (Out, error) {
+
+			mid, err := p.run(ctx, offset, value)
+
+			if err != nil {
+				var zero Out
+				return zero, err
+			}
+
+			return apply(ctx, offset+step, mid, f)
+		},
+	}
+}
+
+// Return a Pipeline that passes the output of p to q.
+func Append[In, Mid, Out any](p Pipeline[In, Mid], q Pipeline[Mid, Out]) Pipeline[In, Out] {
+
+	return Pipeline[In, Out]{
+		steps:     p.steps + q.steps,
+		described: slices.Concat(p.described, q.described),
+		run: func(ctx context.Context, offset int, value In) (Out, error) {
+
+			mid, err := p.run(ctx, offset, value)
+
+			if err != nil {
+				var zero Out
+				return zero, err
+			}
+
+			return q.run(ctx, offset+p.steps, mid)
+		},
+	}
+}
+
+// Return a single-step Pipeline that applies the given Transformer.
+//
+// This is the escape hatch from Transformer to Pipeline. The compiler can no
+// longer check that the Transformer accepts In and returns Out, so a mismatch
+// is reported at run time as a *ComposeError, just as it would be by Compose().
+func FromTransformer[In, Out any](transformer Transformer) Pipeline[In, Out] {
+
+	p := then(NewPipeline[In](), func(ctx context.Context, value In) (Out, error) {
+
+		var zero Out
+
+		result, err := call(ctx, transformer, value)
+
+		if err != nil {
+			return zero, err
+		}
+
+		typed, ok := as[Out](result)
+
+		if !ok {
+			return zero, fmt.Errorf(
+				"FromTransformer() expected a result of type %v, got %v of type %T",
+				reflect.TypeFor[Out](),
+				result,
+				result)
+		}
+
+		return typed, nil
+	})
+
+	d := describe("", transformer)
+	d.In, d.Out = reflect.TypeFor[In](), reflect.TypeFor[Out]()
+	p.described = append(p.described, d)
+	return p
+}
+
+// Return the number of steps in p.
+func (p Pipeline[In, Out]) Len() int {
+	return p.steps
+}
+
+// Run p with the given input.
+//
+// Returns the final result and nil, or the zero value of Out and a
+// *ComposeError if any step panics or returns an error.
+func (p Pipeline[In, Out]) Run(value In) (Out, error) {
+	return p.RunContext(context.Background(), value)
+}
+
+// Run p with the given input, checking ctx before each step in the manner of
+// ComposeContext().
+func (p Pipeline[In, Out]) RunContext(ctx context.Context, value In) (Out, error) {
+	return p.run(ctx, 0, value)
+}
+
+// Return a Transformer that runs p.
+//
+// This is the escape hatch from Pipeline to Transformer, allowing typed
+// pipelines to be used as steps in calls to Compose(). The returned Transformer
+// fails if passed a value that is not of type In, where nil is of any interface
+// type.
+func (p Pipeline[In, Out]) Transformer() Transformer {
+
+	s := &stage{
+		run: func(ctx context.Context, value any) (any, error) {
+
+			typed, ok := as[In](value)
+
+			if !ok {
+				return nil, fmt.Errorf(
+					"Pipeline expected a value of type %v, got %v of type %T",
+					reflect.TypeFor[In](),
+					value,
+					value)
+			}
+
+			return p.RunContext(ctx, typed)
+		},
+		in:   reflect.TypeFor[In](),
+		out:  reflect.TypeFor[Out](),
+		name: "Pipeline",
+		children: func() []Description {
+
+			children := slices.Clone(p.described)
+
+			for i := range children {
+				children[i].Label = strconv.Itoa(i)
+			}
+
+			return children
+		},
+	}
+
+	return s.transformer()
+}
+
+// Return value as a T, and whether that was possible. Unlike a type assertion,
+// this accepts nil when T is an interface type, such as any, returning its zero
+// value, since nil is how an any holds the zero value of every interface type.
+func as[T any](value any) (T, bool) {
+
+	typed, ok := value.(T)
+
+	if !ok && value == nil && reflect.TypeFor[T]().Kind() == reflect.Interface {
+		return typed, true
+	}
+
+	return typed, ok
+}
+
+// Apply a single typed step of a Pipeline, converting a panic or error into a
+// *ComposeError that records the given step index.
+func apply[In, Out any](ctx context.Context, step int, value In, f func(context.Context, In) (Out, error)) (result Out, err error) {
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			var zero Out
+			result, err = zero, newComposeError(step, value, recovered, debug.Stack())
+		}
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return result, newStepError(step, value, err)
+	}
+
+	result, err = f(ctx, value)
+
+	if err != nil {
+		var zero Out
+		return zero, newStepError(step, value, err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,188 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+
+	p := Map(
+		Then(NewPipeline[string](), strconv.Atoi),
+		func(n int) float64 { return float64(n) / 2.0 })
+
+	if p.Len() != 2 {
+		t.Errorf("expected 2 steps, got %d", p.Len())
+	}
+
+	result, err := p.Run("3")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if result != 1.5 {
+		t.Errorf("expected 1.5, got %v", result)
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+
+	count := 0
+
+	p := Map(
+		Then(
+			Map(NewPipeline[string](), func(s string) string { count += 1; return s }),
+			strconv.Atoi),
+		func(n int) int { count += 1; return n })
+
+	_, err := p.Run("three")
+
+	var numError *strconv.NumError
+
+	if !errors.As(err, &numError) {
+		t.Fatalf("expected a *strconv.NumError, got %v", err)
+	}
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 1 {
+		t.Errorf("expected step 1, got %d", composeError.Step)
+	}
+
+	if count != 1 {
+		t.Errorf("expected only the first step to run, got %d", count)
+	}
+}
+
+func TestPipelinePanic(t *testing.T) {
+
+	p := Map(NewPipeline[[]int](), func(s []int) int { return s[1] })
+
+	_, err := p.Run([]int{})
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 0 || !composeError.IsRuntimeError() {
+		t.Errorf("expected a runtime error in step 0, got %+v", composeError)
+	}
+}
+
+func TestPipelineContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := Map(NewPipeline[int](), func(n int) int { return n + 1 })
+
+	_, err := p.RunContext(ctx, 0)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPipelineAppend(t *testing.T) {
+
+	p := Map(NewPipeline[int](), func(n int) int { return n + 1 })
+	q := Map(Map(NewPipeline[int](), strconv.Itoa), func(s string) string { return s + s })
+	r := Append(p, Append(q, FromTransformer[string, int](MakeTransformer(func(s string) string { return s }))))
+
+	if r.Len() != 4 {
+		t.Errorf("expected 4 steps, got %d", r.Len())
+	}
+
+	// The final step returns a string where an int is expected, which cannot be
+	// detected until run time since it goes through a Transformer.
+	_, err := r.Run(1)
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 3 {
+		t.Errorf("expected step 3, got %d", composeError.Step)
+	}
+}
+
+func TestPipelineTransformer(t *testing.T) {
+
+	p := Then(NewPipeline[string](), strconv.Atoi)
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	result, err := Compose("41", p.Transformer(), add)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if result != 42 {
+		t.Errorf("expected 42, got %v", result)
+	}
+
+	q := Map(FromTransformer[int, int](add), func(n int) string { return strconv.Itoa(n) })
+
+	s, err := q.Run(1)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s != "2" {
+		t.Errorf("expected \"2\", got %q", s)
+	}
+
+	_, err = Compose("forty-one", p.Transformer())
+
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected strconv.ErrSyntax, got %v", err)
+	}
+}
+
+func TestPipelineNilInterface(t *testing.T) {
+
+	nothing := Transformer(func(any) any { return nil })
+
+	result, err := FromTransformer[int, any](nothing).Run(1)
+
+	if err != nil || result != nil {
+		t.Errorf("expected nil and no error, got %v and %v", result, err)
+	}
+
+	describe := Map(NewPipeline[any](), func(value any) string { return fmt.Sprint(value) })
+
+	if result, err := Compose(1, nothing, describe.Transformer()); err != nil || result != "<nil>" {
+		t.Errorf("expected \"<nil>\" and no error, got %v and %v", result, err)
+	}
+
+	// nil is not an int, so is still rejected where an int is expected.
+	if _, err := FromTransformer[int, int](nothing).Run(1); err == nil {
+		t.Errorf("expected an error for a nil int")
+	}
+
+	if _, err := Compose(nil, Map(NewPipeline[int](), strconv.Itoa).Transformer()); err == nil {
+		t.Errorf("expected an error for a nil int")
+	}
+
+	_, err = Compose("one", Map(NewPipeline[int](), strconv.Itoa).Transformer())
+
+	if err == nil || !strings.Contains(err.Error(), "Pipeline expected a value of type int, got one of type string") {
+		t.Errorf("expected a type mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Returned, wrapped in a *DefinitionError, when a pipeline definition names a
+// Transformer that has not been registered.
+var ErrUnknownTransformer = errors.New("unknown Transformer")
+
+// Creates a Transformer from the JSON-encoded parameters given for it in a
+// pipeline definition, which are nil if none were given.
+type Factory func(params json.RawMessage) (Transformer, error)
+
+// A set of Transformers, or factories for them, registered under names so that
+// pipelines can be defined as data, e.g. in configuration files, rather than
+// in Go code.
+//
+// A Registry is safe for concurrent use.
+type Registry struct {
+	mutex     sync.RWMutex
+	factories map[string]Factory
+}
+
+// Error returned when a stage of a pipeline definition cannot be built.
+type DefinitionError struct {
+
+	// Zero-based index of the stage within the definition.
+	Stage int
+
+	// The name given for the stage.
+	Name string
+
+	// What was wrong with it.
+	Err error
+}
+
+// Implement the error interface.
+func (e *DefinitionError) Error() string {
+	return fmt.Sprintf("stage %d (%q): %v", e.Stage, e.Name, e.Err)
+}
+
+// Support errors.Is() and errors.As().
+func (e *DefinitionError) Unwrap() error {
+	return e.Err
+}
+
+// One stage of a pipeline definition, as it appears in the JSON documents read
+// by Registry.Load().
+type StageDefinition struct {
+
+	// The name under which the Transformer was registered.
+	Name string `json:"name"`
+
+	// Parameters passed to the Transformer's Factory, if any.
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// A pipeline definition, as it appears in the JSON documents read by
+// Registry.Load(), e.g.:
+//
+//	{
+//	  "stages": [
+//	    { "name": "add", "params": { "n": 3 } },
+//	    { "name": "double" }
+//	  ]
+//	}
+type PipelineDefinition struct {
+	Stages []StageDefinition `json:"stages"`
+}
+
+// Return an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: map[string]Factory{}}
+}
+
+// Register a Transformer that takes no parameters under the given name.
+//
+// Returns an error if the name is invalid or already registered.
+func (r *Registry) Register(name string, transformer Transformer) error {
+
+	return r.RegisterFactory(name, func(params json.RawMessage) (Transformer, error) {
+
+		if !isNull(params) {
+
+			// Tolerate an empty object, e.g. "name{}".
+			empty := map[string]any{}
+
+			if json.Unmarshal(params, &empty) != nil || len(empty) > 0 {
+				return nil, fmt.Errorf("%q takes no parameters", name)
+			}
+		}
+
+		return transformer, nil
+	})
+}
+
+// Register a Factory under the given name.
+//
+// Returns an error if the name is invalid or already registered.
+func (r *Registry) RegisterFactory(name string, factory Factory) error {
+
+	if name == "" || strings.ContainsAny(name, "{}, \t\n") {
+		return fmt.Errorf("invalid Transformer name %q", name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.factories[name]; ok {
+		return fmt.Errorf("Transformer %q is already registered", name)
+	}
+
+	r.factories[name] = factory
+	return nil
+}
+
+// Register a function that creates a Transformer from parameters of type P
+// under the given name.
+//
+// The parameters given in a pipeline definition are decoded into a P using
+// encoding/json, rejecting fields that P does not have. When none are given,
+// factory is passed the zero value of P. For example:
+//
+//	lib.RegisterParameterized(registry, "add", func(p struct{ N int }) (lib.Transformer, error) {
+//		return lib.MakeTransformer(func(value int) int { return value + p.N }), nil
+//	})
+//
+// Note that this is a function rather than a method because Go does not
+// support generic methods.
+func RegisterParameterized[P any](r *Registry, name string, factory func(params P) (Transformer, error)) error {
+
+	return r.RegisterFactory(name, func(raw json.RawMessage) (Transformer, error) {
+
+		var params P
+
+		if !isNull(raw) {
+
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.DisallowUnknownFields()
+
+			if err := decoder.Decode(&params); err != nil {
+				return nil, fmt.Errorf("invalid parameters: %w", err)
+			}
+
+			if !atEOF(decoder) {
+				return nil, errors.New("invalid parameters: unexpected data after the parameters")
+			}
+		}
+
+		return factory(params)
+	})
+}
+
+// Return the names of all registered Transformers, in sorted order.
+func (r *Registry) Names() []string {
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0, len(r.factories))
+
+	for name := range r.factories {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// Return the Transformer registered under the given name, created using the
+// given JSON-encoded parameters, which may be nil.
+//
+// The Transformer is Named() after its definition, e.g. `add{"n":3}`, so that
+// Describe() reports how it was built.
+func (r *Registry) Build(name string, params json.RawMessage) (Transformer, error) {
+
+	r.mutex.RLock()
+	factory, ok := r.factories[name]
+	r.mutex.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownTransformer
+	}
+
+	transformer, err := factory(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !isNull(params) {
+		name += string(bytes.TrimSpace(params))
+	}
+
+	return Named(name, transformer), nil
+}
+
+// Return the Transformer described by a string of the form name or
+// name{params}, e.g. "double" or `add{"n":3}`, where {params} is a JSON object.
+//
+// Returns a *DefinitionError for stage 0 if the Transformer cannot be built.
+func (r *Registry) Parse(spec string) (Transformer, error) {
+
+	name, params := spec, json.RawMessage(nil)
+
+	if i := strings.IndexByte(spec, '{'); i >= 0 {
+		name, params = spec[:i], json.RawMessage(spec[i:])
+	}
+
+	return r.build(0, StageDefinition{Name: strings.TrimSpace(name), Params: params})
+}
+
+// Return the Transformers for the stages of a pipeline definition read as JSON
+// from the given reader, ready to be passed to Compose().
+//
+// Returns a *DefinitionError identifying the first stage that names an unknown
+// Transformer or has invalid parameters, or an error from encoding/json if the
+// document itself is malformed.
+func (r *Registry) Load(reader io.Reader) ([]Transformer, error) {
+
+	definition := PipelineDefinition{}
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&definition); err != nil {
+		return nil, fmt.Errorf("invalid pipeline definition: %w", err)
+	}
+
+	if !atEOF(decoder) {
+		return nil, errors.New("invalid pipeline definition: unexpected data after the definition")
+	}
+
+	return r.Define(definition)
+}
+
+// Return the Transformers for the stages of the given pipeline definition.
+func (r *Registry) Define(definition PipelineDefinition) ([]Transformer, error) {
+
+	transformers := make([]Transformer, 0, len(definition.Stages))
+
+	for i, stage := range definition.Stages {
+
+		transformer, err := r.build(i, stage)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transformers = append(transformers, transformer)
+	}
+
+	return transformers, nil
+}
+
+// Build the given stage, wrapping any error in a *DefinitionError.
+func (r *Registry) build(i int, stage StageDefinition) (Transformer, error) {
+
+	transformer, err := r.Build(stage.Name, stage.Params)
+
+	if err != nil {
+		return nil, &DefinitionError{Stage: i, Name: stage.Name, Err: err}
+	}
+
+	return transformer, nil
+}
+
+// Return true if and only if the given parameters are absent or JSON null.
+func isNull(params json.RawMessage) bool {
+
+	trimmed := bytes.TrimSpace(params)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null"))
+}
+
+// Return true if and only if nothing but white space remains to be decoded.
+func atEOF(decoder *json.Decoder) bool {
+
+	_, err := decoder.Token()
+	return err == io.EOF
+}
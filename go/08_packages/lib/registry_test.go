@@ -0,0 +1,159 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Return a Registry containing "add", which takes a parameter, and "double",
+// which does not.
+func testRegistry(t *testing.T) *Registry {
+
+	registry := NewRegistry()
+
+	err := RegisterParameterized(registry, "add", func(params struct{ N int }) (Transformer, error) {
+
+		if params.N == 0 {
+			return nil, errors.New("n must not be zero")
+		}
+
+		return MakeTransformer(func(value int) int { return value + params.N }), nil
+	})
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := registry.Register("double", MakeTransformer(func(value int) int { return value * 2 })); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return registry
+}
+
+func TestRegistryLoad(t *testing.T) {
+
+	registry := testRegistry(t)
+
+	transformers, err := registry.Load(strings.NewReader(`
+		{
+			"stages": [
+				{ "name": "add", "params": { "n": 3 } },
+				{ "name": "double" },
+				{ "name": "add", "params": { "n": -1 } }
+			]
+		}`))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	result, err := Compose(1, transformers...)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if result != 7 {
+		t.Errorf("expected 7, got %v", result)
+	}
+}
+
+func TestRegistryLoadErrors(t *testing.T) {
+
+	registry := testRegistry(t)
+
+	for _, test := range []struct {
+		document string
+		stage    int
+		message  string
+	}{
+		{`{"stages":[{"name":"double"},{"name":"triple"}]}`, 1, `stage 1 ("triple"): unknown Transformer`},
+		{`{"stages":[{"name":"add","params":{"m":3}}]}`, 0, `unknown field "m"`},
+		{`{"stages":[{"name":"add","params":{"n":"three"}}]}`, 0, `cannot unmarshal string`},
+		{`{"stages":[{"name":"add"}]}`, 0, `n must not be zero`},
+		{`{"stages":[{"name":"double","params":{"n":2}}]}`, 0, `"double" takes no parameters`},
+	} {
+
+		_, err := registry.Load(strings.NewReader(test.document))
+
+		var definitionError *DefinitionError
+
+		if !errors.As(err, &definitionError) {
+			t.Errorf("%s: expected a *DefinitionError, got %v", test.document, err)
+			continue
+		}
+
+		if definitionError.Stage != test.stage {
+			t.Errorf("%s: expected stage %d, got %d", test.document, test.stage, definitionError.Stage)
+		}
+
+		if !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: expected %q in %q", test.document, test.message, err.Error())
+		}
+	}
+
+	if _, err := registry.Load(strings.NewReader(`{"steps":[]}`)); err == nil {
+		t.Errorf("expected an error for an unknown field")
+	}
+
+	if _, err := registry.Load(strings.NewReader(`{"stages":[]} {}`)); err == nil {
+		t.Errorf("expected an error for trailing data")
+	}
+}
+
+func TestRegistryParse(t *testing.T) {
+
+	registry := testRegistry(t)
+
+	add, err := registry.Parse(`add{"n":3}`)
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	double, err := registry.Parse("double")
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err := registry.Parse("double{}"); err != nil {
+		t.Errorf("expected empty parameters to be accepted, got %v", err)
+	}
+
+	if result, err := Compose(1, add, double); err != nil || result != 8 {
+		t.Errorf("expected 8 and no error, got %v and %v", result, err)
+	}
+
+	if _, err := registry.Parse("triple"); !errors.Is(err, ErrUnknownTransformer) {
+		t.Errorf("expected ErrUnknownTransformer, got %v", err)
+	}
+
+	if _, err := registry.Parse(`add{"n":3}}`); err == nil {
+		t.Errorf("expected an error for trailing data")
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+
+	registry := testRegistry(t)
+	identity := Transformer(func(value any) any { return value })
+
+	if err := registry.Register("double", identity); err == nil {
+		t.Errorf("expected an error for a duplicate name")
+	}
+
+	if err := registry.Register("add{}", identity); err == nil {
+		t.Errorf("expected an error for an invalid name")
+	}
+
+	names := registry.Names()
+
+	if strings.Join(names, ",") != "add,double" {
+		t.Errorf("expected [add double], got %v", names)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"slices"
+)
+
+// Outcome of ComposeResumable(), which, unlike Compose(), retains the work
+// done by the steps that succeeded before one failed.
+type Result struct {
+
+	// The final value if Err is nil, otherwise the last value successfully
+	// produced, i.e. the one passed to the step that failed.
+	Value any
+
+	// The number of steps that completed, which is also the index of the step
+	// that failed, if any.
+	Completed int
+
+	// Why the run stopped early, or nil if it did not.
+	Err error
+
+	// What is needed to resume the run.
+	composer     *Composer
+	transformers []Transformer
+}
+
+// Like ComposeContext() but return a *Result recording how far the run got
+// rather than discarding the work of earlier steps when one fails.
+//
+// For the same reason, steps created by MakeCompensable() are not compensated
+// when a later one fails, here or in Result.Resume(): the Result still counts
+// them as completed, so undoing them would lose their effects when the run is
+// resumed.
+func ComposeResumable(ctx context.Context, value any, transformers ...Transformer) *Result {
+	return defaultComposer.ComposeResumable(ctx, value, transformers...)
+}
+
+// Like ComposeResumable(context.Context, any, ...Transformer) but with the
+// behavior configured for c.
+func (c *Composer) ComposeResumable(ctx context.Context, value any, transformers ...Transformer) *Result {
+
+	r := &Result{Value: value, composer: c, transformers: slices.Clone(transformers)}
+
+	if c.validate {
+		if r.Err = Validate(value, transformers...); r.Err != nil {
+			return r
+		}
+	}
+
+	r.Value, r.Completed, r.Err = c.compose(ctx, value, r.transformers, 0, true)
+	return r
+}
+
+// Return the result of continuing r's run from the step that failed, passing it
+// r.Value, or r itself if the run did not fail.
+//
+// If replacement is not nil, it is used in place of the Transformer that
+// failed, both now and by any later call to Resume() on the returned Result.
+// Otherwise, the same Transformer is tried again, e.g. after fixing whatever
+// external condition caused it to fail.
+//
+// Steps reported by the returned Result have the same indices as in the
+// original chain.
+func (r *Result) Resume(ctx context.Context, replacement Transformer) *Result {
+
+	if r.Err == nil {
+		return r
+	}
+
+	resumed := &Result{composer: r.composer, transformers: slices.Clone(r.transformers)}
+
+	if replacement != nil && r.Completed < len(resumed.transformers) {
+		resumed.transformers[r.Completed] = replacement
+	}
+
+	resumed.Value, resumed.Completed, resumed.Err = r.composer.compose(
+		ctx,
+		r.Value,
+		resumed.transformers,
+		r.Completed,
+		true)
+
+	return resumed
+}
@@ -0,0 +1,132 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestComposeResumable(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	broken := true
+
+	flaky := MakeFallibleTransformer(func(value int) (int, error) {
+
+		if broken {
+			return 0, errors.New("not yet")
+		}
+
+		return value * 10, nil
+	})
+
+	result := ComposeResumable(context.Background(), 1, add, add, flaky, add)
+
+	if result.Err == nil || result.Value != 3 || result.Completed != 2 {
+		t.Fatalf("expected 3 after 2 steps and an error, got %+v", result)
+	}
+
+	if err, ok := result.Err.(*ComposeError); !ok || err.Step != 2 {
+		t.Errorf("expected a *ComposeError for step 2, got %v", result.Err)
+	}
+
+	// Retrying the same Transformer fails the same way.
+	again := result.Resume(context.Background(), nil)
+
+	if again.Err == nil || again.Value != 3 || again.Completed != 2 {
+		t.Errorf("expected 3 after 2 steps and an error, got %+v", again)
+	}
+
+	broken = false
+	resumed := again.Resume(context.Background(), nil)
+
+	if resumed.Err != nil || resumed.Value != 31 || resumed.Completed != 4 {
+		t.Errorf("expected 31 after 4 steps and no error, got %+v", resumed)
+	}
+
+	if resumed.Resume(context.Background(), nil) != resumed {
+		t.Errorf("expected resuming a successful run to return the same Result")
+	}
+}
+
+func TestResumeReplacement(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	div := MakeTransformer(func(value int) int { return 100 / value })
+	half := MakeTransformer(func(value int) int { return value / 2 })
+
+	result := ComposeResumable(context.Background(), -1, add, div, div)
+
+	if result.Completed != 1 || result.Value != 0 {
+		t.Fatalf("expected 0 after 1 step, got %+v", result)
+	}
+
+	// The replacement is only used for the step that failed, so the second div
+	// fails in turn.
+	result = result.Resume(context.Background(), half)
+
+	if result.Completed != 2 || result.Value != 0 || result.Err == nil {
+		t.Fatalf("expected 0 after 2 steps and an error, got %+v", result)
+	}
+
+	if err, ok := result.Err.(*ComposeError); !ok || err.Step != 2 {
+		t.Errorf("expected a *ComposeError for step 2, got %v", result.Err)
+	}
+}
+
+func TestComposeResumableCompensable(t *testing.T) {
+
+	log := []string{}
+	broken := map[int]bool{1: true, 3: true}
+
+	// Return a Transformer for the given step that fails while it is broken.
+	flaky := func(step int) Transformer {
+
+		return MakeFallibleTransformer(func(value int) (int, error) {
+
+			if broken[step] {
+				return 0, errors.New("not yet")
+			}
+
+			return value * 10, nil
+		})
+	}
+
+	result := ComposeResumable(context.Background(), 0, provision("a", &log), flaky(1), provision("b", &log), flaky(3))
+
+	if result.Completed != 1 || strings.Join(log, ",") != "+a" {
+		t.Fatalf("expected 1 step completed and nothing compensated, got %d and %v", result.Completed, log)
+	}
+
+	// Step 2 completes during this call but is not compensated either, so that
+	// resuming again does not lose its effects.
+	broken[1] = false
+	result = result.Resume(context.Background(), nil)
+
+	if result.Completed != 3 || strings.Join(log, ",") != "+a,+b" {
+		t.Fatalf("expected 3 steps completed and nothing compensated, got %d and %v", result.Completed, log)
+	}
+
+	broken[3] = false
+	result = result.Resume(context.Background(), nil)
+
+	if result.Err != nil || result.Value != 110 || strings.Join(log, ",") != "+a,+b" {
+		t.Errorf("expected 110, no error and each step run once, got %v, %v and %v", result.Value, result.Err, log)
+	}
+}
+
+func TestComposeResumableValidates(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	composer := NewComposer(ValidateTypes())
+	result := composer.ComposeResumable(context.Background(), "1", add)
+
+	var typeError *TypeError
+
+	if !errors.As(result.Err, &typeError) || result.Completed != 0 || result.Value != "1" {
+		t.Errorf("expected a *TypeError before any step, got %+v", result)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Determines how a Transformer created by WithRetry() retries the Transformer
+// it wraps.
+//
+// The zero value makes a single attempt, i.e. does not retry at all.
+type RetryPolicy struct {
+
+	// Maximum number of attempts, including the first; values less than 1 are
+	// treated as 1.
+	MaxAttempts int
+
+	// Delay before the second attempt.
+	InitialDelay time.Duration
+
+	// Factor by which the delay grows after each subsequent attempt; values
+	// less than 1 are treated as 2.
+	Multiplier float64
+
+	// Upper bound on the delay between attempts, before jitter is applied, or
+	// zero for no bound.
+	MaxDelay time.Duration
+
+	// Fraction, between 0 and 1, of each delay that is randomized. For
+	// example, with a Jitter of 0.25 a delay of 100ms becomes a random delay of
+	// between 75ms and 100ms. This keeps many clients that failed at the same
+	// time from retrying in lockstep.
+	Jitter float64
+
+	// Decide whether a failed attempt should be retried. Exactly one of
+	// recovered and err is non-nil: recovered is the value passed to panic() if
+	// the attempt panicked, otherwise err is the error it returned. A nil
+	// Retryable retries every failure.
+	Retryable func(recovered any, err error) bool
+
+	// Clock used to wait between attempts, or nil for SystemClock.
+	Clock Clock
+
+	// Source of random numbers in [0, 1) used for jitter, or nil for
+	// math/rand.Float64().
+	Random func() float64
+}
+
+// Return a Transformer that invokes the given one, retrying according to the
+// given policy when it panics or returns an error.
+//
+// Waiting between attempts is abandoned when the context passed by
+// ComposeContext() is done. If the final attempt fails, the pipeline fails in
+// the same way, i.e. with a panic or an error, and the resulting ComposeError's
+// Attempts is the number of attempts made.
+//
+// The returned Transformer accepts and returns the same types as the one it
+// wraps, as far as Validate() is concerned, and is compensated in the same way
+// if the one it wraps was created by MakeCompensable().
+func WithRetry(transformer Transformer, policy RetryPolicy) Transformer {
+
+	s := &stage{
+		run: func(ctx context.Context, value any) (any, error) {
+			return policy.run(ctx, transformer, value)
+		},
+		name: fmt.Sprintf("WithRetry (at most %d attempts)", max(policy.MaxAttempts, 1)),
+		children: func() []Description {
+			return []Description{describe("", transformer)}
+		},
+	}
+
+	if inner := inspect(transformer); inner != nil {
+		s.in, s.out, s.undo = inner.in, inner.out, inner.undo
+	}
+
+	return s.transformer()
+}
+
+// Invoke transformer, retrying according to p.
+func (p RetryPolicy) run(ctx context.Context, transformer Transformer, value any) (any, error) {
+
+	attempts := max(p.MaxAttempts, 1)
+	clock := p.Clock
+
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	delay := p.bound(p.InitialDelay)
+
+	for attempt := 1; ; attempt++ {
+
+		result, err := try(ctx, transformer, value)
+
+		if err == nil {
+			return result, nil
+		}
+
+		if attempt >= attempts || !p.retryable(err) {
+			return nil, &retried{attempts: attempt, err: err}
+		}
+
+		select {
+		case <-clock.After(p.jitter(delay)):
+		case <-ctx.Done():
+			return nil, &retried{attempts: attempt, err: err}
+		}
+
+		delay = p.next(delay)
+	}
+}
+
+// Return true if and only if the given failure should be retried.
+func (p RetryPolicy) retryable(err error) bool {
+
+	if p.Retryable == nil {
+		return true
+	}
+
+	if recovered, ok := err.(*panicked); ok {
+		return p.Retryable(recovered.recovered, nil)
+	}
+
+	return p.Retryable(nil, err)
+}
+
+// Return the delay that follows the given one.
+func (p RetryPolicy) next(delay time.Duration) time.Duration {
+
+	multiplier := p.Multiplier
+
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	return p.bound(time.Duration(float64(delay) * multiplier))
+}
+
+// Return the given delay limited to MaxDelay, if any.
+func (p RetryPolicy) bound(delay time.Duration) time.Duration {
+
+	if p.MaxDelay > 0 {
+		return min(delay, p.MaxDelay)
+	}
+
+	return delay
+}
+
+// Return the given delay with jitter applied.
+func (p RetryPolicy) jitter(delay time.Duration) time.Duration {
+
+	if p.Jitter <= 0 {
+		return delay
+	}
+
+	random := p.Random
+
+	if random == nil {
+		random = rand.Float64
+	}
+
+	return delay - time.Duration(float64(delay)*min(p.Jitter, 1)*random())
+}
@@ -0,0 +1,214 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Return a Transformer that fails the given number of times before adding 1 to
+// its argument, and a pointer to the number of times it has been called.
+func flaky(failures int, fail func()) (Transformer, *int) {
+
+	calls := 0
+
+	return MakeTransformer(func(value int) int {
+
+		calls += 1
+
+		if calls <= failures {
+			fail()
+		}
+
+		return value + 1
+	}), &calls
+}
+
+func TestWithRetry(t *testing.T) {
+
+	clock := newFakeClock()
+	transformer, calls := flaky(2, func() { panic("locked") })
+
+	policy := RetryPolicy{
+		MaxAttempts:  5,
+		InitialDelay: 10 * time.Millisecond,
+		Clock:        clock,
+	}
+
+	result, err := Compose(0, WithRetry(transformer, policy))
+
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if result != 1 || *calls != 3 {
+		t.Errorf("expected 1 after 3 calls, got %v after %d", result, *calls)
+	}
+
+	expected := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}
+
+	if len(clock.waited) != len(expected) {
+		t.Fatalf("expected delays %v, got %v", expected, clock.waited)
+	}
+
+	for i, delay := range expected {
+		if clock.waited[i] != delay {
+			t.Errorf("expected delay %v at %d, got %v", delay, i, clock.waited[i])
+		}
+	}
+}
+
+func TestWithRetryExhausted(t *testing.T) {
+
+	clock := newFakeClock()
+	transformer, calls := flaky(10, func() { panic("locked") })
+
+	policy := RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		Multiplier:   3,
+		MaxDelay:     2 * time.Second,
+		Clock:        clock,
+	}
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	_, err := Compose(0, add, WithRetry(transformer, policy))
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", err, err)
+	}
+
+	if composeError.Step != 1 || composeError.Attempts != 3 || composeError.Recovered != "locked" {
+		t.Errorf("expected a panic in step 1 after 3 attempts, got %+v", composeError)
+	}
+
+	if !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("expected the message to report attempts, got %q", err.Error())
+	}
+
+	if *calls != 3 {
+		t.Errorf("expected 3 calls, got %d", *calls)
+	}
+
+	if len(clock.waited) != 2 || clock.waited[0] != time.Second || clock.waited[1] != 2*time.Second {
+		t.Errorf("expected delays [1s 2s], got %v", clock.waited)
+	}
+}
+
+func TestWithRetryMaxDelay(t *testing.T) {
+
+	clock := newFakeClock()
+	transformer, _ := flaky(2, func() { panic("locked") })
+
+	policy := RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: 5 * time.Second,
+		MaxDelay:     2 * time.Second,
+		Clock:        clock,
+	}
+
+	if _, err := Compose(0, WithRetry(transformer, policy)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(clock.waited) != 2 || clock.waited[0] != 2*time.Second || clock.waited[1] != 2*time.Second {
+		t.Errorf("expected delays [2s 2s], got %v", clock.waited)
+	}
+}
+
+func TestWithRetryPredicate(t *testing.T) {
+
+	permanent := errors.New("permanent")
+	clock := newFakeClock()
+
+	calls := 0
+
+	fail := MakeFallibleTransformer(func(value int) (int, error) {
+		calls += 1
+		return 0, permanent
+	})
+
+	policy := RetryPolicy{
+		MaxAttempts: 5,
+		Clock:       clock,
+		Retryable: func(recovered any, err error) bool {
+			return !errors.Is(err, permanent)
+		},
+	}
+
+	_, err := Compose(0, WithRetry(fail, policy))
+
+	if !errors.Is(err, permanent) {
+		t.Fatalf("expected permanent, got %v", err)
+	}
+
+	var composeError *ComposeError
+
+	if errors.As(err, &composeError) && composeError.Attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", composeError.Attempts)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
+
+func TestWithRetryJitter(t *testing.T) {
+
+	clock := newFakeClock()
+	transformer, _ := flaky(1, func() { panic("locked") })
+
+	policy := RetryPolicy{
+		MaxAttempts:  2,
+		InitialDelay: 100 * time.Millisecond,
+		Jitter:       0.25,
+		Clock:        clock,
+		Random:       func() float64 { return 0.5 },
+	}
+
+	if _, err := Compose(0, WithRetry(transformer, policy)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(clock.waited) != 1 || clock.waited[0] != 87500*time.Microsecond {
+		t.Errorf("expected a delay of 87.5ms, got %v", clock.waited)
+	}
+}
+
+func TestWithRetryCancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	transformer, calls := flaky(10, func() { cancel(); panic("locked") })
+
+	// The system clock would wait an hour if cancellation were ignored.
+	policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Hour}
+
+	_, err := ComposeContext(ctx, 0, WithRetry(transformer, policy))
+
+	var composeError *ComposeError
+
+	if !errors.As(err, &composeError) || composeError.Attempts != 1 {
+		t.Errorf("expected to give up after 1 attempt, got %v", err)
+	}
+
+	if *calls != 1 {
+		t.Errorf("expected 1 call, got %d", *calls)
+	}
+}
+
+func TestWithRetryValidate(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	if err := Validate("0", WithRetry(add, RetryPolicy{})); err == nil {
+		t.Errorf("expected WithRetry() to preserve the wrapped Transformer's types")
+	}
+}
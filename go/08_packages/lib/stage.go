@@ -0,0 +1,151 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"reflect"
+	"runtime/debug"
+)
+
+// Behavior attached to Transformers created by this package's own
+// constructors, beyond what can be expressed by the Transformer function type
+// itself.
+//
+// A Transformer is just a function, so there is nowhere to store additional
+// information alongside it. Instead, every stage-backed Transformer is the same
+// function literal, returned by stage.transformer(), closed over a *stage. That
+// literal answers a private probe value by handing back its *stage, which
+// inspect() uses to recover the stage from an arbitrary Transformer. Because
+// only that one function literal knows how to answer a probe, inspect() can
+// tell whether it is safe to send one by comparing code pointers, and so never
+// invokes a Transformer supplied by client code just to find out what it is.
+type stage struct {
+
+	// Apply the stage to a value, honoring ctx where that makes sense.
+	run func(ctx context.Context, value any) (any, error)
+
+	// The type of value the stage accepts, or nil if unknown.
+	in reflect.Type
+
+	// The type of value the stage returns, or nil if unknown.
+	out reflect.Type
+
+	// Undo the effects of a successful run that returned the given value, or
+	// nil if the stage cannot be compensated.
+	undo func(output any) error
+
+	// The function from which the stage was created by MakeMapper(), and so by
+	// MakeTransformer(), or nil; used by ComposeOf() to avoid boxing.
+	typed any
+
+	// The name reported by Describe(), or empty to derive one from in and out.
+	name string
+
+	// Return the Descriptions of the Transformers invoked by the stage, or nil
+	// if it invokes none.
+	children func() []Description
+}
+
+// Private type of value sent to a stage-backed Transformer by inspect(). It
+// has no fields so that converting it to any does not allocate.
+type probe struct{}
+
+// Wraps an error returned by a stage when it is invoked directly as a
+// Transformer, i.e. other than by Compose() or ComposeContext(), since the only
+// way a Transformer can report failure is by panicking.
+type stageFailure struct {
+	err error
+}
+
+// Implement the error interface.
+func (f *stageFailure) Error() string {
+	return f.err.Error()
+}
+
+// Support errors.Is() and errors.As().
+func (f *stageFailure) Unwrap() error {
+	return f.err
+}
+
+// Return the Transformer backed by s.
+//
+// This must not be inlined, since the inlined copies of the returned function
+// literal would have different code pointers than the one recorded in
+// stageCode.
+//
+//go:noinline
+func (s *stage) transformer() Transformer {
+
+	return func(value any) any {
+
+		if _, ok := value.(probe); ok {
+			return s
+		}
+
+		result, err := s.run(context.Background(), value)
+
+		if err != nil {
+			panic(&stageFailure{err: err})
+		}
+
+		return result
+	}
+}
+
+// Code pointer shared by every Transformer returned by stage.transformer().
+var stageCode = reflect.ValueOf((&stage{}).transformer()).Pointer()
+
+// Return the *stage backing the given Transformer, or nil if it was not created
+// by stage.transformer().
+func inspect(transformer Transformer) *stage {
+
+	if transformer == nil || reflect.ValueOf(transformer).Pointer() != stageCode {
+		return nil
+	}
+
+	s, _ := transformer(probe{}).(*stage)
+	return s
+}
+
+// Apply the given Transformer to the given value, passing ctx through to it if
+// it is stage-backed. A panic is allowed to propagate to the caller.
+func call(ctx context.Context, transformer Transformer, value any) (any, error) {
+	return callStage(ctx, inspect(transformer), transformer, value)
+}
+
+// Like call() but for a Transformer whose *stage, as returned by inspect(), is
+// already known, since inspecting it is by far the most expensive part of
+// invoking it.
+func callStage(ctx context.Context, s *stage, transformer Transformer, value any) (any, error) {
+
+	if s != nil {
+		return s.run(ctx, value)
+	}
+
+	return transformer(value), nil
+}
+
+// Apply the given Transformer to the given value like call(), but recover from
+// a panic, returning it as a *panicked error.
+func try(ctx context.Context, transformer Transformer, value any) (result any, err error) {
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result, err = nil, recoveredError(recovered, debug.Stack())
+		}
+	}()
+
+	return call(ctx, transformer, value)
+}
+
+// Return the error to report for a recovered panic: the error carried by a
+// *stageFailure, or else a *panicked.
+func recoveredError(recovered any, stack []byte) error {
+
+	if failure, ok := recovered.(*stageFailure); ok {
+		return failure.err
+	}
+
+	return &panicked{recovered: recovered, stack: stack}
+}
@@ -0,0 +1,120 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"sync"
+)
+
+// Apply the given Transformer functions to each value received from in, in the
+// manner of ComposeContext(), sending the results to the first returned
+// channel.
+//
+// See Composer.ComposeStream() for details.
+func ComposeStream(ctx context.Context, in <-chan any, transformers ...Transformer) (<-chan any, <-chan error) {
+	return defaultComposer.ComposeStream(ctx, in, transformers...)
+}
+
+// Cause the channels created by Composer.ComposeStream() to be buffered to hold
+// the given number of values.
+func BufferSize(size int) Option {
+
+	return func(c *Composer) {
+		c.buffer = size
+	}
+}
+
+// Apply the given Transformer functions to each value received from in,
+// sending the results to the first returned channel and a *ComposeError for
+// each value that causes a Transformer to fail to the second.
+//
+// Each Transformer runs in its own goroutine, connected to its neighbors by
+// channels whose capacity is set by the BufferSize() Option, so that
+// successive values are processed concurrently by successive stages. Values
+// emerge in the order in which they were received. A value that causes a
+// Transformer to fail is dropped after its error is sent; the failing stage
+// and those around it carry on with the next value.
+//
+// Like worker() in ../../10_concurrency/concurrency.go, each stage closes the
+// channel on which it sends values when it exits, which happens when in is
+// closed and drained or ctx is done. The error channel is closed once every
+// stage has exited. The caller must therefore close in or cancel ctx to stop the
+// stages and must keep receiving from both returned channels until they are
+// closed (or cancel ctx) to avoid blocking them.
+//
+// The ValidateTypes() Option has no effect on streams since there is no
+// initial value to validate.
+func (c *Composer) ComposeStream(ctx context.Context, in <-chan any, transformers ...Transformer) (<-chan any, <-chan error) {
+
+	errs := make(chan error, c.buffer)
+	wg := &sync.WaitGroup{}
+
+	for step, transformer := range transformers {
+
+		out := make(chan any, c.buffer)
+		wg.Add(1)
+		go c.streamStage(ctx, step, transformer, in, out, errs, wg)
+		in = out
+	}
+
+	// Close the error channel only once no stage can send on it.
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+
+	return in, errs
+}
+
+// Goroutine that applies a single Transformer to each value received from in.
+func (c *Composer) streamStage(
+	ctx context.Context,
+	step int,
+	transformer Transformer,
+	in <-chan any,
+	out chan<- any,
+	errs chan<- error,
+	wg *sync.WaitGroup,
+) {
+
+	defer wg.Done()
+
+	// When terminating, close the channel on which this stage sends values so
+	// that the next stage terminates in turn.
+	defer close(out)
+
+	s := inspect(transformer)
+
+	for {
+
+		var value any
+		var ok bool
+
+		select {
+		case value, ok = <-in:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+
+		result, err := c.invoke(ctx, step, s, transformer, value)
+
+		if err != nil {
+			select {
+			case errs <- err:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case out <- result:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
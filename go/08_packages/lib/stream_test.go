@@ -0,0 +1,148 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// Send the given values on a new channel, closing it once they have all been
+// sent.
+func produce(values ...any) <-chan any {
+
+	in := make(chan any)
+
+	go func() {
+
+		defer close(in)
+
+		for _, value := range values {
+			in <- value
+		}
+	}()
+
+	return in
+}
+
+// Receive from both channels returned by ComposeStream() until both are closed.
+func drain(out <-chan any, errs <-chan error) ([]any, []error) {
+
+	results := []any{}
+	failures := []error{}
+
+	for out != nil || errs != nil {
+
+		select {
+
+		case value, ok := <-out:
+			if !ok {
+				out = nil
+				continue
+			}
+			results = append(results, value)
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			failures = append(failures, err)
+		}
+	}
+
+	return results, failures
+}
+
+func TestComposeStream(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	double := MakeTransformer(func(value int) int { return value * 2 })
+
+	out, errs := ComposeStream(context.Background(), produce(0, 1, 2, 3), add, double)
+	results, failures := drain(out, errs)
+
+	if len(failures) != 0 {
+		t.Errorf("expected no errors, got %v", failures)
+	}
+
+	expected := []any{2, 4, 6, 8}
+
+	if len(results) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, results)
+	}
+
+	for i, value := range expected {
+		if results[i] != value {
+			t.Errorf("expected %v at %d, got %v", value, i, results[i])
+		}
+	}
+}
+
+func TestComposeStreamPanic(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	out, errs := NewComposer(BufferSize(4)).ComposeStream(
+		context.Background(),
+		produce(0, "one", 2),
+		add,
+		add)
+
+	results, failures := drain(out, errs)
+
+	if len(results) != 2 || results[0] != 2 || results[1] != 4 {
+		t.Errorf("expected [2 4], got %v", results)
+	}
+
+	if len(failures) != 1 {
+		t.Fatalf("expected one error, got %v", failures)
+	}
+
+	var composeError *ComposeError
+
+	if !errors.As(failures[0], &composeError) {
+		t.Fatalf("expected a *ComposeError, got %v of type %T", failures[0], failures[0])
+	}
+
+	if composeError.Step != 0 || composeError.Value != "one" {
+		t.Errorf("expected step 0 with value \"one\", got %+v", composeError)
+	}
+}
+
+func TestComposeStreamCancel(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Never closed, so only cancellation can stop the stages.
+	in := make(chan any)
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	out, errs := ComposeStream(ctx, in, add, add, add)
+
+	in <- 0
+
+	if value := <-out; value != 3 {
+		t.Errorf("expected 3, got %v", value)
+	}
+
+	cancel()
+
+	results, failures := drain(out, errs)
+
+	if len(results) != 0 || len(failures) != 0 {
+		t.Errorf("expected nothing after cancellation, got %v and %v", results, failures)
+	}
+}
+
+func TestComposeStreamEmpty(t *testing.T) {
+
+	out, errs := ComposeStream(context.Background(), produce(1, 2))
+	results, failures := drain(out, errs)
+
+	if len(results) != 2 || len(failures) != 0 {
+		t.Errorf("expected [1 2] and no errors, got %v and %v", results, failures)
+	}
+}
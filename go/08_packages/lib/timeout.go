@@ -0,0 +1,100 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Returned, wrapped, by a Transformer created by WithTimeout() when the one it
+// wraps does not finish in time.
+var ErrTimeout = errors.New("timed out")
+
+// Return a Transformer that invokes the given one in a separate goroutine and
+// fails with an error wrapping ErrTimeout if it does not finish within the
+// given duration. A duration of zero or less means no timeout.
+//
+// The context passed to the wrapped Transformer, if it was created by
+// MakeContextTransformer() or is otherwise context-aware, is cancelled when the
+// timeout expires, so well-behaved Transformers stop promptly. Go provides no
+// way to stop a goroutine from the outside, however, so one that ignores its
+// context, e.g. because it is stuck in an infinite loop, is abandoned: it runs
+// until it returns, if ever, and whatever it then returns, or panics with, is
+// discarded. Such goroutines are the only ones leaked; in the normal case, the
+// goroutine exits as soon as the wrapped Transformer returns.
+//
+// If the context passed by ComposeContext() is done before the timeout
+// expires, the Transformer fails with that context's error instead.
+//
+// The returned Transformer accepts and returns the same types as the one it
+// wraps, as far as Validate() is concerned, and is compensated in the same way
+// if the one it wraps was created by MakeCompensable().
+func WithTimeout(transformer Transformer, d time.Duration) Transformer {
+
+	s := &stage{
+		run: func(ctx context.Context, value any) (any, error) {
+			return runWithTimeout(ctx, transformer, value, d)
+		},
+		name: fmt.Sprintf("WithTimeout (%v)", d),
+		children: func() []Description {
+			return []Description{describe("", transformer)}
+		},
+	}
+
+	if inner := inspect(transformer); inner != nil {
+		s.in, s.out, s.undo = inner.in, inner.out, inner.undo
+	}
+
+	return s.transformer()
+}
+
+// The outcome of invoking a Transformer in a separate goroutine.
+type outcome struct {
+	value any
+	err   error
+}
+
+// Invoke transformer, giving up after d.
+func runWithTimeout(ctx context.Context, transformer Transformer, value any, d time.Duration) (any, error) {
+
+	if d <= 0 {
+		return call(ctx, transformer, value)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	// Buffered so that the goroutine can always send its outcome and exit, even
+	// after it has been abandoned.
+	done := make(chan outcome, 1)
+
+	go func() {
+		result, err := try(timeout, transformer, value)
+		done <- outcome{value: result, err: err}
+	}()
+
+	select {
+
+	case o := <-done:
+		return o.value, o.err
+
+	case <-timeout.Done():
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w after %v", ErrTimeout, d)
+	}
+}
+
+// Cause the Composer to wrap each step using WithTimeout() with the given
+// duration, bounding the time taken by each step even when the Transformers
+// themselves were not written with timeouts in mind.
+func StepTimeout(d time.Duration) Option {
+
+	return func(c *Composer) {
+		c.timeout = d
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	defer close(release)
+
+	hang := MakeTransformer(func(value int) int {
+		<-release
+		return value
+	})
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	_, err := Compose(1, add, WithTimeout(hang, 10*time.Millisecond))
+
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	if err.(*ComposeError).Step != 1 {
+		t.Errorf("expected step 1 to have timed out, got %v", err)
+	}
+
+	result, err := Compose(1, WithTimeout(add, time.Minute), WithTimeout(add, 0))
+
+	if err != nil || result != 3 {
+		t.Errorf("expected 3 and no error, got %v and %v", result, err)
+	}
+}
+
+func TestWithTimeoutCancelsContext(t *testing.T) {
+
+	cancelled := make(chan error, 1)
+
+	wait := MakeContextTransformer(func(ctx context.Context, value any) (any, error) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return value, nil
+	})
+
+	if _, err := Compose(1, WithTimeout(wait, 10*time.Millisecond)); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+
+	if err := <-cancelled; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wrapped Transformer's context to have expired, got %v", err)
+	}
+}
+
+func TestWithTimeoutFailures(t *testing.T) {
+
+	div := MakeTransformer(func(value int) int { return 100 / value })
+
+	_, err := Compose(0, WithTimeout(div, time.Minute))
+
+	if composeError, ok := err.(*ComposeError); !ok || !composeError.IsRuntimeError() {
+		t.Errorf("expected a runtime error, got %v", err)
+	}
+
+	fail := MakeFallibleTransformer(func(value int) (int, error) { return 0, errors.New("failed") })
+
+	if _, err := Compose(0, WithTimeout(fail, time.Minute)); err == nil || errors.Is(err, ErrTimeout) {
+		t.Errorf("expected the wrapped Transformer's error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	defer close(release)
+
+	hang := MakeTransformer(func(value int) int {
+		cancel()
+		<-release
+		return value
+	})
+
+	if _, err := ComposeContext(ctx, 0, WithTimeout(hang, time.Minute)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestStepTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	defer close(release)
+
+	hang := func(value any) any {
+		<-release
+		return value
+	}
+
+	composer := NewComposer(StepTimeout(10 * time.Millisecond))
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	if result, err := composer.Compose(1, add, add); err != nil || result != 3 {
+		t.Errorf("expected 3 and no error, got %v and %v", result, err)
+	}
+
+	_, err := composer.Compose(1, add, hang, add)
+
+	if composeError, ok := err.(*ComposeError); !ok || composeError.Step != 1 || !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected step 1 to have timed out, got %v", err)
+	}
+}
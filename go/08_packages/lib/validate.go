@@ -0,0 +1,105 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Error returned by Validate() when a Transformer would be passed a value of a
+// type it does not accept.
+type TypeError struct {
+
+	// Zero-based index of the Transformer that would fail.
+	Step int
+
+	// The type of value the Transformer accepts.
+	Want reflect.Type
+
+	// The type of value it would be passed, or nil if it would be passed nil.
+	Got reflect.Type
+}
+
+// Implement the error interface.
+func (e *TypeError) Error() string {
+
+	got := "nil"
+
+	if e.Got != nil {
+		got = e.Got.String()
+	}
+
+	if e.Step == 0 {
+		return fmt.Sprintf(
+			"Transformer 0 accepts %v but the initial value is %s",
+			e.Want,
+			got)
+	}
+
+	return fmt.Sprintf(
+		"Transformer %d accepts %v but Transformer %d returns %s",
+		e.Step,
+		e.Want,
+		e.Step-1,
+		got)
+}
+
+// Check that each of the given Transformer functions accepts the type of value
+// it would be passed by Compose(initial, transformers...) without invoking any
+// of them.
+//
+// Only Transformers created by this package's constructors, such as
+// MakeTransformer(), record the types they accept and return. Any other
+// Transformer is assumed to accept whatever it is passed and the type of its
+// result is treated as unknown, so the step after it is not checked either.
+//
+// Returns nil if no mismatch was found, otherwise a *TypeError describing the
+// first one.
+func Validate(initial any, transformers ...Transformer) error {
+
+	current := reflect.TypeOf(initial)
+	known := true
+
+	for step, transformer := range transformers {
+
+		s := inspect(transformer)
+
+		if s == nil {
+			known = false
+			continue
+		}
+
+		if known && s.in != nil && !accepts(s.in, current) {
+			return &TypeError{Step: step, Want: s.in, Got: current}
+		}
+
+		current = s.out
+		known = s.out != nil
+	}
+
+	return nil
+}
+
+// Return false if and only if a type assertion to want of a value whose static
+// type is got is certain to fail. A nil got denotes the nil value, for which
+// every type assertion fails.
+func accepts(want reflect.Type, got reflect.Type) bool {
+
+	switch {
+
+	case got == nil:
+		return false
+
+	case got.Kind() == reflect.Interface:
+		// The dynamic type of the value is unknown, so the best that can be
+		// done is to reject assertions the compiler would consider impossible.
+		return want.Kind() == reflect.Interface || want.Implements(got)
+
+	case want.Kind() == reflect.Interface:
+		return got.Implements(want)
+
+	default:
+		return got == want
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright Kirk Rader 2024
+
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	if err := Validate(0, add, add); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+
+	if err := Validate(0); err != nil {
+		t.Errorf("expected no error for an empty chain, got %v", err)
+	}
+}
+
+func TestValidateMismatch(t *testing.T) {
+
+	count := 0
+
+	add := MakeTransformer(func(value int) int {
+		count += 1
+		return value + 1
+	})
+
+	sub := MakeTransformer(func(value float64) float64 {
+		count += 1
+		return value - 1.0
+	})
+
+	err := Validate(0, add, add, sub, add)
+
+	var typeError *TypeError
+
+	if !errors.As(err, &typeError) {
+		t.Fatalf("expected a *TypeError, got %v of type %T", err, err)
+	}
+
+	if typeError.Step != 2 {
+		t.Errorf("expected step 2, got %d", typeError.Step)
+	}
+
+	if typeError.Want != reflect.TypeFor[float64]() || typeError.Got != reflect.TypeFor[int]() {
+		t.Errorf("expected float64 and int, got %v and %v", typeError.Want, typeError.Got)
+	}
+
+	if !strings.Contains(err.Error(), "float64") || !strings.Contains(err.Error(), "int") {
+		t.Errorf("expected message to name both types, got %q", err.Error())
+	}
+
+	if count != 0 {
+		t.Errorf("expected no Transformer to have been called, got %d", count)
+	}
+}
+
+func TestValidateInitial(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	var typeError *TypeError
+
+	if err := Validate("0", add); !errors.As(err, &typeError) || typeError.Step != 0 {
+		t.Errorf("expected a *TypeError for step 0, got %v", err)
+	}
+
+	if err := Validate(nil, add); !errors.As(err, &typeError) || typeError.Got != nil {
+		t.Errorf("expected a *TypeError for nil, got %v", err)
+	}
+}
+
+func TestValidateUnknown(t *testing.T) {
+
+	add := MakeTransformer(func(value int) int { return value + 1 })
+	sub := MakeTransformer(func(value float64) float64 { return value - 1.0 })
+	opaque := Transformer(func(value any) any { return float64(value.(int)) })
+
+	// The type returned by opaque is unknown, so the step after it cannot be
+	// checked.
+	if err := Validate(0, add, opaque, sub); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestValidateInterfaces(t *testing.T) {
+
+	stringer := MakeTransformer(func(value fmt.Stringer) fmt.Stringer { return value })
+	anything := MakeTransformer(func(value any) any { return value })
+	add := MakeTransformer(func(value int) int { return value + 1 })
+
+	if err := Validate(reflect.TypeFor[int](), stringer); err != nil {
+		t.Errorf("expected reflect.Type to satisfy fmt.Stringer, got %v", err)
+	}
+
+	if err := Validate(0, stringer); err == nil {
+		t.Errorf("expected int not to satisfy fmt.Stringer")
+	}
+
+	if err := Validate(0, anything, add); err != nil {
+		t.Errorf("expected any to be assertable to int, got %v", err)
+	}
+
+	if err := Validate(reflect.TypeFor[int](), stringer, add); err == nil {
+		t.Errorf("expected asserting fmt.Stringer to int to be impossible")
+	}
+}
+
+func TestComposerValidateTypes(t *testing.T) {
+
+	count := 0
+
+	add := MakeTransformer(func(value int) int {
+		count += 1
+		return value + 1
+	})
+
+	sub := MakeTransformer(func(value float64) float64 {
+		count += 1
+		return value - 1.0
+	})
+
+	composer := NewComposer(ValidateTypes())
+
+	result, err := composer.Compose(0, add, add, sub, add)
+
+	var typeError *TypeError
+
+	if !errors.As(err, &typeError) {
+		t.Fatalf("expected a *TypeError, got %v of type %T", err, err)
+	}
+
+	if result != nil {
+		t.Errorf("expected result to be nil, got %v of type %T", result, result)
+	}
+
+	if count != 0 {
+		t.Errorf("expected no Transformer to have been called, got %d", count)
+	}
+
+	result, err = composer.Compose(0, add, add)
+
+	if err != nil || result != 2 {
+		t.Errorf("expected 2 and no error, got %v and %v", result, err)
+	}
+}